@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/pytoolbelt/psenv/internal/adopt"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var adoptPathFlag string
+var adoptPrefixFlag string
+var adoptProjectFlag string
+var adoptMoveFlag bool
+var adoptDryRunFlag bool
+var adoptForceFlag bool
+var adoptKeyIDFlag string
+
+func adoptEntryPoint(cmd *cobra.Command, args []string) {
+	if adoptPathFlag == "" {
+		fmt.Println("Please specify the path to adopt with --path.")
+		os.Exit(1)
+	}
+
+	// the target only differs from the scanned path when parameters are moved
+	prefix, project := adopt.Target(adoptPathFlag)
+	if cmd.Flags().Changed("prefix") || cmd.Flags().Changed("project") {
+		if !adoptMoveFlag {
+			fmt.Println("Use --prefix and --project together with --move.")
+			os.Exit(1)
+		}
+		if cmd.Flags().Changed("prefix") {
+			prefix = adoptPrefixFlag
+		}
+		if cmd.Flags().Changed("project") {
+			project = adoptProjectFlag
+		}
+	}
+
+	// don't overwrite a project that was set up already
+	if !adoptDryRunFlag && !adoptForceFlag {
+		for _, file := range []string{config.ProjectConfigFile, config.SecretsConfigFile} {
+			if _, err := os.Stat(file); err == nil {
+				fmt.Printf("%s already exists, use --force to overwrite it.\n", file)
+				os.Exit(1)
+			}
+		}
+	}
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	ps, err := parameterstore.New(clientOptions(&config.ProjectConfig{}, "")...)
+	if err != nil {
+		fmt.Printf("error creating ssm paramstore %s\n", err)
+		os.Exit(1)
+	}
+
+	params, err := ps.GetParametersWithMetadata(ctx, adoptPathFlag, true)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if len(params) == 0 {
+		fmt.Printf("No parameters found in the parameter store on path %s\n", adoptPathFlag)
+		os.Exit(1)
+	}
+
+	plan, err := adopt.NewPlan(adoptPathFlag, prefix, project, params, adoptMoveFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	plan.Print(os.Stdout)
+	if adoptDryRunFlag {
+		os.Exit(0)
+	}
+
+	if err := plan.Apply(ctx, ps, adoptKeyIDFlag); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := plan.ProjectConfig().Save(); err != nil {
+		fmt.Printf("error saving project config %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := plan.SecretsConfig().Save(); err != nil {
+		fmt.Printf("error saving secrets config %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// adoptCmd represents the adopt command
+var adoptCmd = &cobra.Command{
+	Use:   "adopt",
+	Short: "Bring an existing parameter tree under psenv",
+	Long: `Scans every parameter below --path and writes psenv-project.yml and psenv-secrets.yml for it.
+The first level below the path is taken as the environment, parameters directly on the path go to base.
+Parameters that are not where psenv expects them are skipped, unless --move moves them into place.`,
+	Run: adoptEntryPoint,
+}
+
+func init() {
+	rootCmd.AddCommand(adoptCmd)
+	adoptCmd.Flags().StringVar(&adoptPathFlag, "path", "", "The path of the parameters to adopt, e.g. /legacy/app")
+	adoptCmd.Flags().StringVar(&adoptPrefixFlag, "prefix", "", "With --move, the prefix to move the parameters to (default the parent of --path)")
+	adoptCmd.Flags().StringVar(&adoptProjectFlag, "project", "", "With --move, the project to move the parameters to (default the last level of --path)")
+	adoptCmd.Flags().BoolVar(&adoptMoveFlag, "move", false, "Move parameters to where psenv expects them instead of skipping them")
+	adoptCmd.Flags().BoolVar(&adoptDryRunFlag, "dry-run", false, "Only print the plan, don't move parameters or write any files")
+	adoptCmd.Flags().BoolVar(&adoptForceFlag, "force", false, "Overwrite existing psenv-project.yml and psenv-secrets.yml files")
+	adoptCmd.Flags().StringVarP(&adoptKeyIDFlag, "kms-name", "k", "alias/aws/ssm", "KMS key name to encrypt moved secure strings with")
+}
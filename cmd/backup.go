@@ -0,0 +1,232 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/pytoolbelt/psenv/internal/backup"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/engine"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var backupOutFlag string
+var backupInFlag string
+var backupPassphraseFileFlag string
+var restoreBackupEnvsFlag []string
+var restoreBackupPrefixFlag string
+var restoreBackupProjectFlag string
+var restoreBackupOverwriteFlag bool
+var restoreBackupKeyIDFlag string
+
+// backupPassphrase reads the passphrase from --passphrase-file, or from the environment
+func backupPassphrase() (string, error) {
+	if backupPassphraseFileFlag != "" {
+		data, err := os.ReadFile(backupPassphraseFileFlag)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	if passphrase := os.Getenv(backup.PassphraseEnvVar); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", fmt.Errorf("a backup passphrase is required, set %s or use --passphrase-file", backup.PassphraseEnvVar)
+}
+
+func backupEntryPoint(cmd *cobra.Command, args []string) {
+	passphrase, err := backupPassphrase()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	projectConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		fmt.Printf("error loading project config %s\n", err)
+		os.Exit(1)
+	}
+
+	envs := projectConfig.EnvironmentNames()
+	if len(envs) == 0 {
+		fmt.Println("no environments found in the psenv-project.yml file")
+		os.Exit(1)
+	}
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	results := newEngine(projectConfig, projectConfig.GetEnvironmentPath).Get(ctx, envs, engine.GetOptions{
+		Decrypt:      true,
+		WithMetadata: true,
+	})
+	exitIfCancelled(ctx, results)
+
+	archive, err := backup.New(projectConfig, results, time.Now())
+	if err != nil {
+		fmt.Printf("error backing up %s\n", err)
+		os.Exit(1)
+	}
+
+	// write next to the target and rename, so a failed backup never replaces a good one
+	out, err := os.CreateTemp(filepath.Dir(backupOutFlag), filepath.Base(backupOutFlag)+".*")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer os.Remove(out.Name())
+
+	err = backup.Write(out, archive, passphrase)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), backupOutFlag)
+	}
+	if err != nil {
+		fmt.Printf("error writing backup %s\n", err)
+		os.Exit(1)
+	}
+
+	count := 0
+	for _, params := range archive.Environments {
+		count += len(params)
+	}
+	fmt.Printf("Backed up %d parameters of %d environments to %s\n", count, len(archive.Environments), backupOutFlag)
+	os.Exit(0)
+}
+
+func restoreBackupEntryPoint(cmd *cobra.Command, args []string) {
+	passphrase, err := backupPassphrase()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	in, err := os.Open(backupInFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	archive, err := backup.Read(in, passphrase)
+	in.Close()
+	if err != nil {
+		fmt.Printf("error reading backup %s\n", err)
+		os.Exit(1)
+	}
+
+	if cmd.Flags().Changed("prefix") {
+		archive.Prefix = restoreBackupPrefixFlag
+	}
+	if cmd.Flags().Changed("project") {
+		archive.Project = restoreBackupProjectFlag
+	}
+
+	// a local project config supplies the accounts and keys of the environments
+	var base *config.ProjectConfig
+	projectConfig, err := config.LoadProjectConfig()
+	if err == nil {
+		base = projectConfig
+	} else if !os.IsNotExist(err) {
+		fmt.Printf("error loading project config %s\n", err)
+		os.Exit(1)
+	}
+	target := archive.Target(base)
+	if err := target.Validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	envs := restoreBackupEnvsFlag
+	if len(envs) == 0 {
+		envs = archive.EnvironmentNames()
+	}
+	local, err := archive.Params(target, envs)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("restoring the backup of %s from %s\n", strings.Join(envs, ", "), archive.CreatedAt.Local().Format(time.RFC1123))
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	keyID := func(env string) string {
+		if cmd.Flags().Changed("kms-name") {
+			return restoreBackupKeyIDFlag
+		}
+		return target.GetKMSKeyID(env, restoreBackupKeyIDFlag)
+	}
+	results := newEngine(target, target.GetEnvironmentPath).Put(ctx, local, engine.PutOptions{
+		KeyID:         keyID,
+		Overwrite:     restoreBackupOverwriteFlag,
+		ConfirmDelete: confirmDelete(target),
+		Trash:         trashDeleted(target.GetTrashPath(), keyID),
+		Rollback:      !noRollbackFlag,
+	})
+	exitIfCancelled(ctx, results)
+	if err := results.Err(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if base == nil {
+		if err := target.Save(); err != nil {
+			fmt.Printf("error saving project config %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("created %s for the restored project, run psenv get to fetch its secrets\n", config.ProjectConfigFile)
+	}
+	os.Exit(0)
+}
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up every environment of the project to an encrypted file",
+	Long: `Reads every environment of the project with decrypted values and metadata and writes them to a
+single file, encrypted with AES-256-GCM under a key derived from a passphrase. The passphrase is read
+from ` + backup.PassphraseEnvVar + ` or --passphrase-file. Keep it somewhere else than the backup.`,
+	Args: cobra.NoArgs,
+	Run:  backupEntryPoint,
+}
+
+// restoreBackupCmd represents the restore-backup command
+var restoreBackupCmd = &cobra.Command{
+	Use:   "restore-backup",
+	Short: "Restore environments from a backup",
+	Long: `Makes the environments in the parameter store match a backup, as put does with the secrets file.
+Environments are restored where the backup was taken unless --prefix or --project move them. Accounts,
+regions and KMS keys come from the local psenv-project.yml when there is one, and --endpoint-url
+points the restore at another parameter store. Versions start over, the parameter store numbers them.`,
+	Args: cobra.NoArgs,
+	Run:  restoreBackupEntryPoint,
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd, restoreBackupCmd)
+	for _, cmd := range []*cobra.Command{backupCmd, restoreBackupCmd} {
+		cmd.Flags().StringVar(&backupPassphraseFileFlag, "passphrase-file", "", "Read the backup passphrase from this file instead of "+backup.PassphraseEnvVar)
+	}
+
+	backupCmd.Flags().StringVarP(&backupOutFlag, "out", "o", "", "The file to write the backup to, e.g. project.psenvbak")
+	backupCmd.MarkFlagRequired("out")
+
+	restoreBackupCmd.Flags().StringVarP(&backupInFlag, "in", "i", "", "The backup file to restore")
+	restoreBackupCmd.MarkFlagRequired("in")
+	restoreBackupCmd.Flags().StringSliceVarP(&restoreBackupEnvsFlag, "env", "e", nil, "Only restore these environments")
+	restoreBackupCmd.Flags().StringVar(&restoreBackupPrefixFlag, "prefix", "", "Restore under this prefix instead of the one backed up")
+	restoreBackupCmd.Flags().StringVar(&restoreBackupProjectFlag, "project", "", "Restore as this project instead of the one backed up")
+	restoreBackupCmd.Flags().BoolVar(&restoreBackupOverwriteFlag, "overwrite", false, "overwrite existing parameters")
+	restoreBackupCmd.Flags().StringVarP(&restoreBackupKeyIDFlag, "kms-name", "k", "alias/aws/ssm", "KMS key name to use for encryption")
+	addConfirmFlags(restoreBackupCmd)
+	addTrashFlag(restoreBackupCmd)
+	addRollbackFlag(restoreBackupCmd)
+}
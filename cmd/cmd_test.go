@@ -0,0 +1,213 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/devserver/devservertest"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+// runCLIEnvVar makes the test binary run psenv with the arguments after --, so the
+// commands can exit the way they do for real. See TestMain and psenv.
+const runCLIEnvVar = "PSENV_TEST_RUN_CLI"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runCLIEnvVar) != "" {
+		rootCmd.SetArgs(os.Args[slices.Index(os.Args, "--")+1:])
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const testProject = `default: dev
+environments: [base, dev]
+prefix: /psenv
+project: foobar
+`
+
+const testSecrets = `prefix: /psenv
+project: foobar
+environments:
+  base:
+    LOG_LEVEL: info
+  dev:
+    DB_HOST: dev-db
+    LOG_LEVEL: debug
+`
+
+// newTestProject writes the project and secrets files to a temporary directory and
+// returns it along with the url of a dev server for the test
+func newTestProject(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.ProjectConfigFile), []byte(testProject), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.SecretsConfigFile), []byte(testSecrets), 0644))
+	return dir, devservertest.Start(t, "")
+}
+
+// psenv runs the psenv cli in dir against the dev server at url and returns what it
+// printed along with how it exited
+func psenv(t *testing.T, dir, url string, args ...string) (string, error) {
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^$", "--"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		runCLIEnvVar+"=1",
+		config.EndpointURLEnvVar+"="+url,
+		config.RegionEnvVar+"="+devservertest.Region,
+		config.AccessKeyIDEnvVar+"="+devservertest.AccessKeyID,
+		config.SecretAccessKeyEnvVar+"="+devservertest.SecretAccessKey,
+	)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestPutAndGet(t *testing.T) {
+	dir, url := newTestProject(t)
+
+	out, err := psenv(t, dir, url, "put")
+	require.NoError(t, err, out)
+
+	params, err := devservertest.Connect(t, url).GetParameters(context.Background(), "/psenv/foobar", true)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"/psenv/foobar/base/LOG_LEVEL": "info",
+		"/psenv/foobar/dev/DB_HOST":    "dev-db",
+		"/psenv/foobar/dev/LOG_LEVEL":  "debug",
+	}, parameterstore.Values(params))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, config.SecretsConfigFile)))
+	out, err = psenv(t, dir, url, "get", "--decrypt")
+	require.NoError(t, err, out)
+
+	data, err := os.ReadFile(filepath.Join(dir, config.SecretsConfigFile))
+	require.NoError(t, err)
+	var secretsConfig config.SecretsConfig
+	require.NoError(t, yaml.Unmarshal(data, &secretsConfig))
+	require.Equal(t, "dev-db", secretsConfig.Environments["dev"]["DB_HOST"].Value)
+	require.Equal(t, "info", secretsConfig.Environments["base"]["LOG_LEVEL"].Value)
+}
+
+func TestPutFailsWithoutSecretsFile(t *testing.T) {
+	dir, url := newTestProject(t)
+	require.NoError(t, os.Remove(filepath.Join(dir, config.SecretsConfigFile)))
+
+	out, err := psenv(t, dir, url, "put")
+	require.Error(t, err)
+	require.Contains(t, out, config.SecretsConfigFile)
+}
+
+func TestExec(t *testing.T) {
+	dir, url := newTestProject(t)
+	out, err := psenv(t, dir, url, "put")
+	require.NoError(t, err, out)
+
+	// the values of dev win over the ones of base
+	out, err = psenv(t, dir, url, "exec", "--env", "dev", "--", "sh", "-c", `printf "%s %s" "$LOG_LEVEL" "$DB_HOST"`)
+	require.NoError(t, err, out)
+	require.Contains(t, out, "debug dev-db")
+
+	// psenv exits with the exit status of the command
+	out, err = psenv(t, dir, url, "exec", "--env", "dev", "--", "sh", "-c", "exit 3")
+	var exitErr *exec.ExitError
+	require.True(t, errors.As(err, &exitErr), out)
+	require.Equal(t, 3, exitErr.ExitCode())
+}
+
+func TestExecWithoutBase(t *testing.T) {
+	dir, url := newTestProject(t)
+	project := "default: dev\nenvironments: [dev]\nprefix: /psenv\nproject: foobar\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.ProjectConfigFile), []byte(project), 0644))
+	secrets := "prefix: /psenv\nproject: foobar\nenvironments:\n  dev:\n    DB_HOST: dev-db\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.SecretsConfigFile), []byte(secrets), 0644))
+
+	out, err := psenv(t, dir, url, "put")
+	require.NoError(t, err, out)
+
+	out, err = psenv(t, dir, url, "exec", "--env", "dev", "--", "sh", "-c", `printf "%s" "$DB_HOST"`)
+	require.NoError(t, err, out)
+	require.Contains(t, out, "dev-db")
+}
+
+func TestTrashRestoreOfDeletedEnvironment(t *testing.T) {
+	dir, url := newTestProject(t)
+	project := "default: dev\nenvironments:\n- base\n- name: dev\n  region: eu-west-1\n  kms_key_id: alias/dev\nprefix: /psenv\nproject: foobar\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.ProjectConfigFile), []byte(project), 0644))
+	out, err := psenv(t, dir, url, "put")
+	require.NoError(t, err, out)
+
+	out, err = psenv(t, dir, url, "delete", "-e", "dev")
+	require.NoError(t, err, out)
+	batch := regexp.MustCompile(`as batch (\S+),`).FindStringSubmatch(out)
+	require.NotNil(t, batch, out)
+
+	// the settings of dev are kept while its parameters are in the trash
+	dev := config.Environment{Name: "dev", Region: "eu-west-1", KMSKeyID: "alias/dev"}
+	projectConfig := loadTestProjectConfig(t, dir)
+	require.False(t, projectConfig.HasEnvironment("dev"))
+	require.Equal(t, []config.Environment{dev}, projectConfig.DeletedEnvironments)
+
+	out, err = psenv(t, dir, url, "trash", "restore", batch[1], "-e", "dev")
+	require.NoError(t, err, out)
+	projectConfig = loadTestProjectConfig(t, dir)
+	require.Equal(t, dev, projectConfig.GetEnvironment("dev"))
+	require.Empty(t, projectConfig.DeletedEnvironments)
+
+	params, err := devservertest.Connect(t, url).GetParameters(context.Background(), "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+	require.Equal(t, "dev-db", params["/psenv/foobar/dev/DB_HOST"].Value)
+}
+
+func TestTrashPurgeForgetsDeletedEnvironment(t *testing.T) {
+	dir, url := newTestProject(t)
+	out, err := psenv(t, dir, url, "put")
+	require.NoError(t, err, out)
+	out, err = psenv(t, dir, url, "delete", "-e", "dev")
+	require.NoError(t, err, out)
+	require.Len(t, loadTestProjectConfig(t, dir).DeletedEnvironments, 1)
+
+	out, err = psenv(t, dir, url, "trash", "purge", "--all", "-e", "dev")
+	require.NoError(t, err, out)
+	require.Empty(t, loadTestProjectConfig(t, dir).DeletedEnvironments)
+}
+
+// loadTestProjectConfig reads the project config the cli left in dir
+func loadTestProjectConfig(t *testing.T, dir string) *config.ProjectConfig {
+	data, err := os.ReadFile(filepath.Join(dir, config.ProjectConfigFile))
+	require.NoError(t, err)
+	var projectConfig config.ProjectConfig
+	require.NoError(t, yaml.Unmarshal(data, &projectConfig))
+	return &projectConfig
+}
+
+func TestTrashPurgeOfDeletedProtectedEnvironment(t *testing.T) {
+	dir, url := newTestProject(t)
+	project := "default: dev\nenvironments:\n- base\n- name: dev\n  protected: true\nprefix: /psenv\nproject: foobar\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.ProjectConfigFile), []byte(project), 0644))
+	out, err := psenv(t, dir, url, "put")
+	require.NoError(t, err, out)
+	out, err = psenv(t, dir, url, "delete", "-e", "dev", "--confirm", "dev")
+	require.NoError(t, err, out)
+
+	// the trash of dev is as protected as dev was
+	out, err = psenv(t, dir, url, "trash", "purge", "--all", "-e", "dev", "--yes")
+	require.Error(t, err, out)
+	require.Contains(t, out, "environment dev is protected")
+
+	out, err = psenv(t, dir, url, "trash", "purge", "--all", "-e", "dev", "--confirm", "dev")
+	require.NoError(t, err, out)
+	out, err = psenv(t, dir, url, "trash", "list", "-e", "dev")
+	require.NoError(t, err, out)
+	require.Contains(t, out, "The trash of dev is empty")
+}
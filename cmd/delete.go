@@ -32,13 +32,17 @@ func deleteEntryPoint(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	ps, err := parameterstore.New()
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	ps, err := parameterstore.New(clientOptions(projectConfig, deleteEnvName)...)
 	if err != nil {
 		fmt.Printf("error creating ssm paramstore %s\n", err)
 		os.Exit(1)
 	}
 
-	remoteParameterDescriptions, err := ps.DescribeParameters(projectConfig.GetEnvironmentPath(deleteEnvName))
+	// describe with a trailing slash so deleting dev does not also match dev2
+	remoteParameterDescriptions, err := ps.DescribeParameters(ctx, projectConfig.GetEnvironmentPath(deleteEnvName)+"/")
 	if err != nil {
 		fmt.Printf("error describing parameters %s\n", err)
 		os.Exit(1)
@@ -49,13 +53,45 @@ func deleteEntryPoint(cmd *cobra.Command, args []string) {
 		os.Exit(0)
 	}
 
-	err = ps.DeleteParameters(remoteParameterDescriptions)
+	if err := confirmDelete(projectConfig)(deleteEnvName, remoteParameterDescriptions); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	moveToTrash := trashDeleted(projectConfig.GetTrashPath(), func(env string) string {
+		return projectConfig.GetKMSKeyID(env, "alias/aws/ssm")
+	})
+	if moveToTrash != nil {
+		remoteParameters, err := ps.GetParametersWithMetadata(ctx, projectConfig.GetEnvironmentPath(deleteEnvName), true)
+		if err != nil {
+			fmt.Printf("error reading parameters %s\n", err)
+			os.Exit(1)
+		}
+
+		deleting := make(map[string]parameterstore.Parameter, len(remoteParameterDescriptions))
+		for _, name := range remoteParameterDescriptions {
+			if param, ok := remoteParameters[name]; ok {
+				deleting[name] = param
+			}
+		}
+		if _, err := moveToTrash(ctx, ps, deleteEnvName, projectConfig.GetEnvironmentPath(deleteEnvName), deleting); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	err = ps.DeleteParameters(ctx, remoteParameterDescriptions)
 	if err != nil {
 		fmt.Printf("error deleting parameters %s\n", err)
 		os.Exit(1)
 	}
 
-	projectConfig.RemoveEnvironment(deleteEnvName)
+	// an environment with parameters in the trash keeps its settings for trash restore
+	if moveToTrash != nil {
+		projectConfig.DeleteEnvironment(deleteEnvName)
+	} else {
+		projectConfig.RemoveEnvironment(deleteEnvName)
+	}
 
 	err = projectConfig.Save()
 	if err != nil {
@@ -91,4 +127,6 @@ var deleteEnvName string
 func init() {
 	rootCmd.AddCommand(deleteCmd)
 	deleteCmd.Flags().StringVarP(&deleteEnvName, "env", "e", "", "The environment to delete parameters from")
+	addConfirmFlags(deleteCmd)
+	addTrashFlag(deleteCmd)
 }
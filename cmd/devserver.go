@@ -0,0 +1,67 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/devserver"
+	"github.com/spf13/cobra"
+	"net/http"
+	"os"
+	"time"
+)
+
+func devServerEntryPoint(cmd *cobra.Command, args []string) {
+	server, err := devserver.New(devServerFileFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("psenv dev server listening on http://%s\n", devServerAddrFlag)
+	if devServerFileFlag != "" {
+		fmt.Printf("parameters are persisted to %s\n", devServerFileFlag)
+	}
+	fmt.Println("Point psenv at it with:")
+	fmt.Printf("  export %s=http://%s\n", config.EndpointURLEnvVar, devServerAddrFlag)
+
+	// the root command turns Ctrl-C into a cancelled context, so stop serving on it
+	httpServer := &http.Server{Addr: devServerAddrFlag, Handler: server}
+	go func() {
+		<-cmd.Context().Done()
+		ctx, cancel := context.WithTimeout(context.Background(), devServerShutdownTimeout)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+	}()
+
+	err = httpServer.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("error running dev server %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("psenv dev server stopped")
+}
+
+// devServerShutdownTimeout is how long requests in flight get to finish on Ctrl-C
+const devServerShutdownTimeout = 5 * time.Second
+
+// devServerCmd represents the dev-server command
+var devServerCmd = &cobra.Command{
+	Use:   "dev-server",
+	Short: "Run a local in-memory parameter store for development and testing",
+	Long:  ``,
+	Run:   devServerEntryPoint,
+}
+
+var devServerAddrFlag string
+var devServerFileFlag string
+
+func init() {
+	rootCmd.AddCommand(devServerCmd)
+	devServerCmd.Flags().StringVarP(&devServerAddrFlag, "addr", "a", "localhost:4583", "Address to listen on")
+	devServerCmd.Flags().StringVarP(&devServerFileFlag, "file", "f", "", "File to persist parameters to. Parameters are kept in memory only when not set")
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/engine"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/pytoolbelt/psenv/internal/terminal"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+var execShellFlag bool
+var watchFlag bool
+var watchIntervalFlag time.Duration
+var watchSignalFlag string
+var watchEnvFileFlag string
+var shutdownTimeoutFlag time.Duration
+
+// runExec runs the exec command as a supervised child. The signals psenv receives are
+// forwarded to it and psenv exits with its exit status. With --watch the parameters of
+// the environment are polled, and when their versions change the command is restarted,
+// or sent --signal after the new environment was written to its env file.
+func runExec(ctx context.Context, projectConfig *config.ProjectConfig, results engine.Results, args []string) {
+	var sig os.Signal
+	if watchSignalFlag != "" {
+		var err error
+		sig, err = terminal.ParseSignal(watchSignalFlag)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if watchFlag && watchIntervalFlag <= 0 {
+		fmt.Println("The --interval must be greater than zero.")
+		os.Exit(1)
+	}
+
+	// a signalled command can only see changed values through its env file
+	envFile := watchEnvFileFlag
+	if sig != nil && envFile == "" {
+		file, err := os.CreateTemp("", "psenv-env-*")
+		if err != nil {
+			fmt.Printf("error creating env file %s\n", err)
+			os.Exit(1)
+		}
+		file.Close()
+		envFile = file.Name()
+		defer os.Remove(envFile)
+	}
+
+	envVars := func(results engine.Results) ([]string, error) {
+		vars, err := terminalEnvVars(projectConfig, results)
+		if err != nil || envFile == "" {
+			return vars, err
+		}
+		if err := terminal.WriteEnvFile(envFile, vars); err != nil {
+			return nil, fmt.Errorf("error writing env file %s", err)
+		}
+		return append(vars, terminal.EnvFileVar+"="+envFile), nil
+	}
+
+	vars, err := envVars(results)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	supervisor := &terminal.Supervisor{
+		Command: func(envVars []string) (*exec.Cmd, error) {
+			if execShellFlag {
+				return terminal.NewSubShell(childEnv(envVars), args...)
+			}
+			return terminal.NewCommand(childEnv(envVars), args...)
+		},
+		ShutdownTimeout: shutdownTimeoutFlag,
+	}
+
+	if err := supervisor.Start(vars); err != nil {
+		// the exit status a shell uses for a command that can't be found or run
+		fmt.Fprintf(os.Stderr, "error starting command %s\n", err)
+		os.Exit(127)
+	}
+
+	stopForwarding := supervisor.Forward(terminal.ForwardedSignals()...)
+	defer stopForwarding()
+
+	// the ticker only runs with --watch, otherwise we just wait for the command
+	var poll <-chan time.Time
+	if watchFlag {
+		ticker := time.NewTicker(watchIntervalFlag)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	params := results.Params()
+	for {
+		select {
+		case err := <-supervisor.Exited():
+			os.Exit(terminal.ExitCode(err))
+
+		case <-poll:
+			results := getTerminalParams(ctx, projectConfig)
+
+			// once psenv was interrupted or timed out the command decides when we're done.
+			// It has been sent the signal too, so stop polling and wait for it to exit.
+			if ctx.Err() != nil {
+				poll = nil
+				continue
+			}
+			if err := results.Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "error checking for changed parameters %s\n", err)
+				continue
+			}
+
+			latest := results.Params()
+			changed := changedParams(params, latest)
+			if len(changed) == 0 {
+				continue
+			}
+			params = latest
+
+			fmt.Fprintf(os.Stderr, "parameters changed: %s\n", strings.Join(changed, ", "))
+
+			// keep the command running as it is rather than hand it a broken environment
+			vars, err := envVars(results)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+
+			if sig == nil {
+				if err := supervisor.Restart(vars); err != nil {
+					fmt.Printf("error restarting command %s\n", err)
+					os.Exit(1)
+				}
+				continue
+			}
+
+			if err := supervisor.Signal(sig); err != nil {
+				fmt.Fprintf(os.Stderr, "error signalling command %s\n", err)
+			}
+		}
+	}
+}
+
+// changedParams returns the names of the parameters that were added, removed or
+// written to a new version between two reads
+func changedParams(previous, latest map[string]parameterstore.Parameter) []string {
+	previousVersions := parameterstore.Versions(previous)
+	latestVersions := parameterstore.Versions(latest)
+
+	var changed []string
+	for name, version := range latestVersions {
+		if previousVersion, ok := previousVersions[name]; !ok || previousVersion != version {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previousVersions {
+		if _, ok := latestVersions[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -6,21 +6,18 @@ package cmd
 import (
 	"fmt"
 	"github.com/pytoolbelt/psenv/internal/config"
-	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/pytoolbelt/psenv/internal/engine"
 	"github.com/spf13/cobra"
 	"os"
-	"sync"
 )
 
 func getEntryPoint(cmd *cobra.Command, args []string) {
-	var envChan = make(chan string, 10)
-	var paramsChan = make(chan map[string]string, 25)
-	var errorChan = make(chan error, 10)
-	var wg sync.WaitGroup
-	var numberOfWorkers int = 1
 	var environmentsToGet []string
 	var secretsConfig *config.SecretsConfig
 
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
 	fmt.Println("getting parameters from the parameter store")
 
 	projectConfig, err := config.LoadProjectConfig()
@@ -38,58 +35,43 @@ func getEntryPoint(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	if getEnvName == "" {
-		numberOfWorkers = len(projectConfig.Environments)
-	}
-
-	if numberOfWorkers == 0 {
-		fmt.Println("no environments found in the psenv-project.yml file")
-		os.Exit(1)
-	}
-
-	// start the workers to put the parameters
-	for i := 0; i < numberOfWorkers; i++ {
-		wg.Add(1)
-		go mainGetWorker(envChan, errorChan, paramsChan, &wg, projectConfig, getCommandDecryptFlag)
-	}
-
-	// put the configured paths on the channel. These will be used
-	// by the parameter store to get params by path.
+	// get a single environment, or all of the configured ones
 	if getEnvName != "" {
 		environmentsToGet = append(environmentsToGet, getEnvName)
 	} else {
-		for _, env := range projectConfig.Environments {
-			environmentsToGet = append(environmentsToGet, env)
-		}
+		environmentsToGet = append(environmentsToGet, projectConfig.EnvironmentNames()...)
 	}
 
-	// put the environments on the channel
-	for _, env := range environmentsToGet {
-		envChan <- env
+	if len(environmentsToGet) == 0 {
+		fmt.Println("no environments found in the psenv-project.yml file")
+		os.Exit(1)
 	}
 
-	// close the channel so the workers know when they are done
-	close(envChan)
-
-	// wait for the workers to finish
-	wg.Wait()
+	results := newEngine(projectConfig, projectConfig.GetEnvironmentPath).Get(ctx, environmentsToGet, engine.GetOptions{
+		Decrypt:      getCommandDecryptFlag,
+		WithMetadata: true,
+	})
 
-	// close the error channel and check for errors
-	close(errorChan)
-	for err := range errorChan {
+	// on Ctrl-C, timeout or any error report it and leave the secrets file untouched
+	exitIfCancelled(ctx, results)
+	if err := results.Err(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	// close the params channel
-	close(paramsChan)
-
-	// clear all the environments from the secrets file
-	secretsConfig.ClearEnvironments()
+	// clear the environments we got from the secrets file
+	if getEnvName != "" {
+		secretsConfig.ClearEnvironment(getEnvName)
+	} else {
+		secretsConfig.ClearEnvironments()
+	}
 
-	// get the params and update the secrets file
-	for params := range paramsChan {
-		err = secretsConfig.UpdateSecretsConfigFromParameters(params)
+	// update the secrets file with the params, keeping the path template so put
+	// writes them back to the same place
+	secretsConfig.PathTemplate = projectConfig.PathTemplate
+	secretsConfig.PathVars = projectConfig.PathVars
+	for _, result := range results {
+		err = secretsConfig.UpdateSecretsConfigFromParameters(result.Env, result.Path, result.Params)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -105,29 +87,6 @@ func getEntryPoint(cmd *cobra.Command, args []string) {
 	os.Exit(0)
 }
 
-func mainGetWorker(envChan <-chan string, errorChan chan<- error, paramsChan chan<- map[string]string, wg *sync.WaitGroup, projectConfig *config.ProjectConfig, decrypt bool) {
-	defer wg.Done()
-
-	// get the parameter store. If we can't make one for some reason,
-	// just exit as there is nothing to do.
-	ps, err := parameterstore.New()
-	if err != nil {
-		errorChan <- err
-		return
-	}
-
-	for env := range envChan {
-		// get the parameters for a given environment path
-		path := projectConfig.GetEnvironmentPath(env)
-		remoteParams, err := ps.GetParameters(path, decrypt)
-		if err != nil {
-			errorChan <- err
-			return
-		}
-		paramsChan <- remoteParams
-	}
-}
-
 var getCommandDecryptFlag bool
 var getEnvName string
 
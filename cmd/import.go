@@ -0,0 +1,159 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/importer"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+)
+
+var importSourceFlag string
+var importMapFlag map[string]string
+var importApplyFlag bool
+var importKeyIDFlag string
+
+func importEntryPoint(cmd *cobra.Command, args []string) {
+	projectConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		fmt.Printf("Error loading project config %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	ps, err := parameterstore.New(clientOptions(projectConfig, "")...)
+	if err != nil {
+		fmt.Printf("error creating ssm paramstore %s\n", err)
+		os.Exit(1)
+	}
+
+	imp, err := importer.New(args[0], importer.Options{
+		Source:  importSourceFlag,
+		Sources: importMapFlag,
+		Store:   ps,
+	})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	imported, err := imp.Import(ctx)
+	if err != nil {
+		fmt.Printf("error importing %s\n", err)
+		os.Exit(1)
+	}
+
+	// the imported environments go where the project config puts them
+	target := &config.SecretsConfig{
+		Project:      projectConfig.Project,
+		Prefix:       projectConfig.Prefix,
+		PathTemplate: projectConfig.PathTemplate,
+		PathVars:     projectConfig.PathVars,
+	}
+
+	plan, err := importer.NewPlan(ctx, imported, target, func(ctx context.Context, env string) (map[string]parameterstore.Parameter, error) {
+		ps, err := parameterstore.New(clientOptions(projectConfig, env)...)
+		if err != nil {
+			return nil, err
+		}
+		return ps.GetParametersWithMetadata(ctx, target.GetEnvironmentPath(env), true)
+	})
+	if err != nil {
+		fmt.Printf("error planning import %s\n", err)
+		os.Exit(1)
+	}
+
+	plan.Print(os.Stdout)
+	var added []string
+	for env := range imported {
+		if !projectConfig.HasEnvironment(env) {
+			added = append(added, env)
+		}
+	}
+	sort.Strings(added)
+	if len(added) > 0 {
+		fmt.Printf("new environments for %s: %s\n", config.ProjectConfigFile, strings.Join(added, ", "))
+	}
+
+	if !importApplyFlag {
+		fmt.Println("Nothing was written, run again with --apply to import.")
+		os.Exit(0)
+	}
+
+	err = plan.Apply(ctx, func(env string) (*parameterstore.ParameterStore, error) {
+		return parameterstore.New(clientOptions(projectConfig, env)...)
+	}, func(env string) string {
+		if cmd.Flags().Changed("kms-name") {
+			return importKeyIDFlag
+		}
+		return projectConfig.GetKMSKeyID(env, importKeyIDFlag)
+	})
+	if err != nil {
+		fmt.Printf("error importing %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, env := range added {
+		projectConfig.AddEnvironment(env)
+	}
+	if err := projectConfig.Save(); err != nil {
+		fmt.Printf("error saving project config %s\n", err)
+		os.Exit(1)
+	}
+
+	// keep the secrets file in step with what was imported
+	secretsConfig, err := config.LoadSecretsConfig()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		secretsConfig = target
+	}
+	if secretsConfig.Environments == nil {
+		secretsConfig.Environments = make(map[string]config.Secrets)
+	}
+	for env, secrets := range imported {
+		if _, ok := secretsConfig.Environments[env]; !ok {
+			secretsConfig.Environments[env] = make(config.Secrets)
+		}
+		for key, secret := range secrets {
+			secretsConfig.Environments[env][key] = secret
+		}
+	}
+	if err := secretsConfig.Save(); err != nil {
+		fmt.Printf("error saving secrets config %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import <" + strings.Join(importer.Names(), "|") + ">",
+	Short: "Import environments from another tool's layout",
+	Long: `Imports environments with one of the importers and prints what would change in the parameter store.
+Nothing is written until the command is run again with --apply. Parameters are never deleted by an import.
+
+  dotenv   --source holds .env (base), .env.<env> or <env>.env files, or --map dev=dev.env names them
+  chamber  --map dev=myapp-dev maps environments to chamber services, --source is the path above the services`,
+	Args: cobra.ExactArgs(1),
+	Run:  importEntryPoint,
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&importSourceFlag, "source", "", "Where to import from, see above")
+	importCmd.Flags().StringToStringVar(&importMapFlag, "map", nil, "Where to import each environment from, e.g. dev=myapp-dev,prod=myapp-prod")
+	importCmd.Flags().BoolVar(&importApplyFlag, "apply", false, "Write the plan to the parameter store instead of only printing it")
+	importCmd.Flags().StringVarP(&importKeyIDFlag, "kms-name", "k", "alias/aws/ssm", "KMS key name to use for encryption")
+}
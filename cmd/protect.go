@@ -0,0 +1,41 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/confirm"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var confirmFlag string
+var yesFlag bool
+
+// addConfirmFlags adds the flags that confirm destructive operations on protected environments
+func addConfirmFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&confirmFlag, "confirm", "", "Type the name of a protected environment back to confirm deleting from it")
+	cmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Don't prompt. Refused on protected environments unless "+confirm.OverrideEnvVar+" is set")
+}
+
+// confirmDelete returns a check for deleting parameters from an environment, which
+// only has to be confirmed when the environment is protected
+func confirmDelete(projectConfig *config.ProjectConfig) func(env string, names []string) error {
+	confirmer := confirm.New(confirmFlag, yesFlag)
+	return func(env string, names []string) error {
+		if !projectConfig.IsProtected(env) {
+			return nil
+		}
+		return confirmer.Protected(env, fmt.Sprintf("delete %d parameters (%s)", len(names), summarizeNames(names, 5)))
+	}
+}
+
+// summarizeNames lists the first few names and how many more there are
+func summarizeNames(names []string, most int) string {
+	if len(names) <= most {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:most], ", "), len(names)-most)
+}
@@ -6,26 +6,22 @@ package cmd
 import (
 	"fmt"
 	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/engine"
 	"github.com/pytoolbelt/psenv/internal/parameterstore"
-	"github.com/pytoolbelt/psenv/internal/utils"
 	"github.com/spf13/cobra"
 	"os"
-	"sync"
-	"time"
 )
 
 var overwriteFlag bool
+var noRollbackFlag bool
 var keyIDFlag string
 
 func putEntrypoint(cmd *cobra.Command, args []string) {
-
-	var envChan = make(chan string, 10)
-	var paramsChan = make(chan map[string]string, 25)
-	var errorChan = make(chan error, 10)
-	var wg sync.WaitGroup
-	var numberOfWorkers int = 1
 	var environmentsToPut []string
 
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
 	fmt.Println("putting parameters in the parameter store")
 	// we are doing a put operation so load the secrets config file.
 	// if one is not found, just exit as there is nothing to do.
@@ -35,23 +31,24 @@ func putEntrypoint(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if putEnvName == "" {
-		numberOfWorkers = len(secretsConfig.Environments)
-	}
-
-	if numberOfWorkers == 0 {
-		fmt.Println("no environments found in the psenv-secrets.yml file")
-		os.Exit(1)
+	// the project config holds the AWS account details for each environment.
+	// Without one, every environment uses the default AWS config.
+	projectConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		projectConfig = &config.ProjectConfig{}
+	} else {
+		// the path template of the project config wins over the one in the secrets file
+		secretsConfig.PathTemplate = projectConfig.PathTemplate
+		secretsConfig.PathVars = projectConfig.PathVars
 	}
 
-	// start the workers to put the parameters
-	for i := 0; i < numberOfWorkers; i++ {
-		wg.Add(1)
-		go putMainWorker(envChan, errorChan, &wg, secretsConfig)
-	}
+	// an explicit --kms-name wins over the keys configured per environment
+	kmsKeyFlagSet := cmd.Flags().Changed("kms-name")
 
-	// put the configured paths on the channel. These will be used
-	// by the parameter store to get params by path.
 	if putEnvName != "" {
 		environmentsToPut = append(environmentsToPut, putEnvName)
 	} else {
@@ -60,57 +57,38 @@ func putEntrypoint(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	// put the envs on the channel
-	for _, env := range environmentsToPut {
-		envChan <- env
-	}
-
-	// close the path channel as we are done with it.
-	close(envChan)
-
-	// wait for the putWorker workers to finish
-	wg.Wait()
-
-	// close the error channel and check to see if we got any errors from
-	// the putWorker workers. If we did, print them out and exit.
-	close(errorChan)
-	for err := range errorChan {
-		fmt.Println(err)
+	if len(environmentsToPut) == 0 {
+		fmt.Println("no environments found in the psenv-secrets.yml file")
 		os.Exit(1)
 	}
 
-	// it seems we got no errors, so proceed to execute the get workers
-	time.Sleep(5 * time.Second)
-
-	envChan = make(chan string, 10)
-
-	// start the get workers to get the parameters
-	for i := 0; i < numberOfWorkers; i++ {
-		wg.Add(1)
-		go getWorker(envChan, &wg, paramsChan, secretsConfig)
-	}
-
-	// put the environments back on the channel
+	localParams := make(map[string]map[string]parameterstore.Parameter)
 	for _, env := range environmentsToPut {
-		envChan <- env
+		localParams[env] = secretsConfig.GetEnvironmentParams(env)
 	}
 
-	// close the path channel as we are done with it.
-	close(envChan)
-
-	// wait for the getWorker workers to finish
-	wg.Wait()
-
-	// close the params channel
-	close(paramsChan)
+	keyID := func(env string) string {
+		if kmsKeyFlagSet {
+			return keyIDFlag
+		}
+		return projectConfig.GetKMSKeyID(env, keyIDFlag)
+	}
 
-	// clear all the environments that we are processing
-	for _, env := range environmentsToPut {
+	results := newEngine(projectConfig, secretsConfig.GetEnvironmentPath).Put(ctx, localParams, engine.PutOptions{
+		KeyID:         keyID,
+		Overwrite:     overwriteFlag,
+		ConfirmDelete: confirmDelete(projectConfig),
+		Trash:         trashDeleted(secretsConfig.GetTrashPath(), keyID),
+		Rollback:      !noRollbackFlag,
+	})
+
+	// refresh the environments that were fully put with what was read back,
+	// even when others failed, so the secrets file matches the parameter store
+	for _, env := range results.Finished() {
 		secretsConfig.ClearEnvironment(env)
 	}
-	// update the config with the fetch fresh params
-	for params := range paramsChan {
-		err = secretsConfig.UpdateSecretsConfigFromParameters(params)
+	for _, result := range results {
+		err = secretsConfig.UpdateSecretsConfigFromParameters(result.Env, result.Path, result.Params)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -122,64 +100,13 @@ func putEntrypoint(cmd *cobra.Command, args []string) {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	os.Exit(0)
-}
-
-func putMainWorker(envChan <-chan string, errorChan chan<- error, wg *sync.WaitGroup, secretsConfig *config.SecretsConfig) {
-	var swg sync.WaitGroup
-	defer wg.Done()
 
-	// get the parameter store. If we can't make one for some reason,
-	// just exit as there is nothing to do.
-	ps, err := parameterstore.New()
-	if err != nil {
+	exitIfCancelled(ctx, results)
+	if err := results.Err(); err != nil {
 		fmt.Println(err)
-		errorChan <- err
 		os.Exit(1)
 	}
-
-	for env := range envChan {
-		// first get the parameters for a given environment path
-		path := secretsConfig.GetEnvironmentPath(env)
-		remoteParams, err := ps.GetParameters(path, false)
-
-		if err != nil {
-			errorChan <- err
-			continue
-		}
-
-		localParams := secretsConfig.GetEnvironmentParams(env)
-
-		// create a param map to determine what we need to do.
-		parameters := utils.MergeLocalAndRemoteParams(localParams, remoteParams)
-
-		// first if we have any parameters to add, just add them.
-		if len(parameters.ToAdd) > 0 {
-			go putWorker(parameters.ToAdd, &swg)
-			swg.Add(1)
-		} else {
-			fmt.Printf("no parameters to add to environment %s\n", env)
-		}
-
-		// if we have any parameters to delete, just delete them.
-		if len(parameters.ToDelete) > 0 {
-			go deleteWorker(parameters.ToDelete, &swg)
-			swg.Add(1)
-		} else {
-			fmt.Printf("no parameters to update in environment %s\n", env)
-		}
-
-		// if we have any parameters to update, just update them.
-		if len(parameters.ToUpdate) > 0 {
-			go putWorker(parameters.ToUpdate, &swg)
-			swg.Add(1)
-		} else {
-			fmt.Printf("no parameters to delete from environment %s\n", env)
-		}
-
-		// wait for the sub workers to finish
-		swg.Wait()
-	}
+	os.Exit(0)
 }
 
 var putEnvName string
@@ -197,4 +124,12 @@ func init() {
 	putCmd.Flags().BoolVarP(&overwriteFlag, "overwrite", "o", false, "overwrite existing parameters")
 	putCmd.Flags().StringVarP(&keyIDFlag, "kms-name", "k", "alias/aws/ssm", "KMS key name to use for encryption")
 	putCmd.Flags().StringVarP(&putEnvName, "env", "e", "", "environment to put parameters for")
+	addConfirmFlags(putCmd)
+	addTrashFlag(putCmd)
+	addRollbackFlag(putCmd)
+}
+
+// addRollbackFlag adds the flag that leaves a failed put as far as it got
+func addRollbackFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noRollbackFlag, "no-rollback", false, "Don't undo the changes to an environment when a write to it fails")
 }
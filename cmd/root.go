@@ -4,8 +4,15 @@ Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
 	"github.com/spf13/cobra"
 )
 
@@ -19,8 +26,73 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Ctrl-C cancels the requests in flight instead of killing psenv outright,
+	// so commands can report which environments they finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
 }
+
+var endpointURLFlag string
+var regionFlag string
+var accessKeyIDFlag string
+var secretAccessKeyFlag string
+var maxAttemptsFlag int
+var retryModeFlag string
+var verboseFlag bool
+var timeoutFlag time.Duration
+var operationTimeoutFlag time.Duration
+var workersFlag int
+
+// commandContext returns the context for the requests of a command. It is cancelled
+// on Ctrl-C and, when --timeout is set, once the timeout has passed.
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if timeoutFlag <= 0 {
+		return context.WithCancel(cmd.Context())
+	}
+	return context.WithTimeout(cmd.Context(), timeoutFlag)
+}
+
+// clientOptions returns the options for a parameter store client for an environment.
+// The endpoint and retry settings from the project config are overridden by the PSENV_
+// environment variables, which are in turn overridden by the command line flags.
+func clientOptions(projectConfig *config.ProjectConfig, env string) []parameterstore.Option {
+	endpoint := config.EndpointFromEnv()
+	opts := append(projectConfig.GetClientOptions(env), endpoint.ClientOptions()...)
+
+	flags := config.Endpoint{
+		URL:             endpointURLFlag,
+		Region:          regionFlag,
+		AccessKeyID:     accessKeyIDFlag,
+		SecretAccessKey: secretAccessKeyFlag,
+	}
+	opts = append(opts, flags.ClientOptions()...)
+
+	retry := config.Retry{Mode: retryModeFlag, MaxAttempts: maxAttemptsFlag}
+	opts = append(opts, retry.ClientOptions()...)
+	opts = append(opts, parameterstore.WithOperationTimeout(operationTimeoutFlag))
+
+	if verboseFlag {
+		opts = append(opts, parameterstore.WithRetryObserver(func(operation string, attempts int) {
+			fmt.Fprintf(os.Stderr, "[%s] %s took %d attempts (%d retries)\n", env, operation, attempts, attempts-1)
+		}))
+	}
+	return opts
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&endpointURLFlag, "endpoint-url", "", "Custom parameter store endpoint, e.g. LocalStack (env "+config.EndpointURLEnvVar+")")
+	rootCmd.PersistentFlags().StringVar(&regionFlag, "region", "", "AWS region to use for every environment (env "+config.RegionEnvVar+")")
+	rootCmd.PersistentFlags().StringVar(&accessKeyIDFlag, "access-key-id", "", "Static access key id to use with a custom endpoint (env "+config.AccessKeyIDEnvVar+")")
+	rootCmd.PersistentFlags().IntVar(&maxAttemptsFlag, "max-attempts", 0, fmt.Sprintf("Most attempts for a single request when throttled (default %d)", parameterstore.DefaultMaxAttempts))
+	rootCmd.PersistentFlags().StringVar(&retryModeFlag, "retry-mode", "", fmt.Sprintf("Retry mode, standard or adaptive (default %s)", parameterstore.DefaultRetryMode))
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", 0, "Give up on the whole command after this long, e.g. 2m (default no limit)")
+	rootCmd.PersistentFlags().DurationVar(&operationTimeoutFlag, "operation-timeout", parameterstore.DefaultOperationTimeout, "Give up on a single request to the parameter store after this long, including retries")
+	rootCmd.PersistentFlags().IntVar(&workersFlag, "workers", 4, "Most environments to work on at the same time")
+	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "Print extra details such as retried requests")
+	rootCmd.PersistentFlags().StringVar(&secretAccessKeyFlag, "secret-access-key", "", "Static secret access key to use with a custom endpoint (env "+config.SecretAccessKeyEnvVar+")")
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func searchEntryPoint(cmd *cobra.Command, args []string) {
+
+	if len(searchTagsFlag) == 0 {
+		fmt.Println("Must specify at least one tag to search for with --tag key=value")
+		os.Exit(1)
+	}
+
+	tags, err := parseTagFlags(searchTagsFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	projectConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		fmt.Printf("error loading project config %s\n", err)
+		os.Exit(1)
+	}
+
+	environmentsToSearch := projectConfig.EnvironmentNames()
+	if searchEnvName != "" {
+		if !projectConfig.HasEnvironment(searchEnvName) {
+			fmt.Printf("environment %s does not exist in the project configuration.\n", searchEnvName)
+			os.Exit(1)
+		}
+		environmentsToSearch = []string{searchEnvName}
+	}
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	// environments can live in different accounts, so each one is searched with its own client
+	results := newEngine(projectConfig, projectConfig.GetEnvironmentPath).Search(ctx, environmentsToSearch, tags)
+	exitIfCancelled(ctx, results)
+	if err := results.Err(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	params := results.Params()
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(params) == 0 {
+		fmt.Println("No parameters found with the given tags")
+		os.Exit(0)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Type", "Version", "Description"})
+	for _, name := range names {
+		param := params[name]
+		table.Append([]string{param.Name, string(param.Type), strconv.FormatInt(param.Version, 10), param.Description})
+	}
+	table.Render()
+	os.Exit(0)
+}
+
+// parseTagFlags parses a list of key=value strings into a map of tags
+func parseTagFlags(values []string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, value := range values {
+		key, tagValue, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q, tags must be in the form key=value", value)
+		}
+		tags[key] = tagValue
+	}
+	return tags, nil
+}
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Search for parameters in the project by tag",
+	Long:  ``,
+	Run:   searchEntryPoint,
+}
+
+var searchTagsFlag []string
+var searchEnvName string
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringArrayVarP(&searchTagsFlag, "tag", "t", nil, "Tag to search for in the form key=value. Can be given more than once")
+	searchCmd.Flags().StringVarP(&searchEnvName, "env", "e", "", "Only search parameters in this environment")
+}
@@ -4,11 +4,16 @@ Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/pytoolbelt/psenv/internal/engine"
 	"github.com/pytoolbelt/psenv/internal/terminal"
 	"github.com/pytoolbelt/psenv/internal/utils"
 	"os"
-	"sync"
+	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/pytoolbelt/psenv/internal/config"
 	"github.com/spf13/cobra"
@@ -17,19 +22,20 @@ import (
 // terminalEntryPoint is the entry point for the terminal command
 func terminalEntryPoint(cmd *cobra.Command, args []string) {
 
-	var envChan = make(chan string, 10)
-	var paramsChan = make(chan map[string]string, 25)
-	var errorChan = make(chan error, 10)
-	var wg sync.WaitGroup
-	var numberOfWorkers int = 1
 	var projectConfig *config.ProjectConfig
 
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
 	validateEnvName()
 	validateCommand(cmd, args)
 
-	fmt.Printf("Starting terminal session for environment %s\n", terminalEnvName)
-	fmt.Println("Type 'exit' to exit the terminal session.")
-	fmt.Println("")
+	// exec keeps stdout to the command, so it can be used as a container entrypoint
+	if cmd.Use == "terminal" {
+		fmt.Printf("Starting terminal session for environment %s\n", terminalEnvName)
+		fmt.Println("Type 'exit' to exit the terminal session.")
+		fmt.Println("")
+	}
 
 	// load the project config
 	projectConfig, err := config.LoadProjectConfig()
@@ -44,55 +50,92 @@ func terminalEntryPoint(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	// load up the environments to fetch. we always fetch base, plus whatever is specified
-	envChan <- "base"
-	envChan <- terminalEnvName
-
-	// create 2 workers to fetch the parameters
-	for i := 0; i < numberOfWorkers; i++ {
-		wg.Add(1)
-		go mainGetWorker(envChan, errorChan, paramsChan, &wg, projectConfig, NoDecryptFlag)
-	}
-
-	// close the envChan channel
-	close(envChan)
-
-	// wait for the workers to finish
-	wg.Wait()
-
-	// close the paramsChan channel and errorChan channel
-	close(paramsChan)
-	close(errorChan)
-
-	// check for errors
-	for err := range errorChan {
+	// don't start the session with a partial set of parameters
+	results := getTerminalParams(ctx, projectConfig)
+	exitIfCancelled(ctx, results)
+	if err := results.Err(); err != nil {
 		fmt.Printf("error getting parameters %s\n", err)
 		os.Exit(1)
 	}
 
-	// print the parameters
-	paramsToConvert := make(map[string]string)
-
-	for params := range paramsChan {
-		for k, v := range params {
-			paramsToConvert[k] = v
-		}
+	if cmd.Use == "exec" {
+		runExec(ctx, projectConfig, results, args)
+		return
 	}
 
-	// convert the parameters to environment variables
-	envVars := utils.ConvertParamsToEnvVars(paramsToConvert)
+	envVars, err := terminalEnvVars(projectConfig, results)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	term, err := terminal.NewSubShell(envVars, args...)
+	term, err := terminal.NewSubShell(childEnv(envVars), args...)
 	if err != nil {
 		fmt.Printf("error creating terminal %s\n", err)
 		os.Exit(1)
 	}
 
+	// leave with the exit status of the shell
 	err = term.Run()
-	if err != nil {
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
 		fmt.Printf("error starting terminal %s\n", err)
 		os.Exit(1)
 	}
+	os.Exit(terminal.ExitCode(err))
+}
+
+// getTerminalParams gets the parameters of the terminal environment, layered on top of
+// base when the project has one
+func getTerminalParams(ctx context.Context, projectConfig *config.ProjectConfig) engine.Results {
+	return newEngine(projectConfig, projectConfig.GetEnvironmentPath).Get(ctx, projectConfig.LayeredEnvironments(terminalEnvName), engine.GetOptions{
+		Decrypt: NoDecryptFlag,
+	})
+}
+
+// terminalEnvVars converts the parameters of base and the environment to environment
+// variables named by the naming section of the project config, keeping only the ones
+// selected by --only and --exclude. StringList values are already comma joined, so
+// they can be used as environment variables as is.
+func terminalEnvVars(projectConfig *config.ProjectConfig, results engine.Results) ([]string, error) {
+	layers := make([]config.Layer, 0, len(results))
+	for _, result := range results {
+		layers = append(layers, config.Layer{Path: result.Path, Params: result.Params})
+	}
+
+	values, _, err := projectConfig.Naming.EnvVars(layers...)
+	if err != nil {
+		return nil, err
+	}
+	return envOptions().Filter(utils.ConvertParamsToEnvVars(values)), nil
+}
+
+// envOptions returns how parameters are combined with the environment psenv runs in
+func envOptions() terminal.EnvOptions {
+	return terminal.EnvOptions{
+		Clean:   cleanEnvFlag,
+		Keep:    keepEnvFlag,
+		Prefer:  preferFlag,
+		Only:    onlyFlag,
+		Exclude: excludeFlag,
+	}
+}
+
+// childEnv returns the complete environment for a command, warning about every
+// variable that is set both in the environment psenv runs in and as a parameter
+func childEnv(envVars []string) []string {
+	opts := envOptions()
+	env, overridden := opts.BuildEnv(os.Environ(), envVars)
+	if len(overridden) == 0 {
+		return env
+	}
+
+	if opts.Prefer == terminal.PreferLocal {
+		fmt.Fprintf(os.Stderr, "warning: inherited variables override parameters: %s\n", strings.Join(overridden, ", "))
+	} else {
+		fmt.Fprintf(os.Stderr, "warning: parameters override inherited variables: %s\n", strings.Join(overridden, ", "))
+	}
+	return env
 }
 
 func validateEnvName() {
@@ -108,6 +151,21 @@ func validateCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	if err := envOptions().Validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if len(onlyFlag) > 0 && len(excludeFlag) > 0 {
+		fmt.Println("Use either --only or --exclude, not both.")
+		os.Exit(1)
+	}
+
+	if watchFlag && cmd.Use != "exec" {
+		fmt.Println("Only the 'exec' command can watch for changed parameters.")
+		os.Exit(1)
+	}
+
 	if len(args) > 0 && cmd.Use == "terminal" {
 		fmt.Println("The 'terminal' command does not accept arguments after the -- .")
 		os.Exit(1)
@@ -132,13 +190,35 @@ var execCmd = &cobra.Command{
 
 var NoDecryptFlag bool = false
 var terminalEnvName string
+var cleanEnvFlag bool
+var keepEnvFlag []string
+var preferFlag string
+var onlyFlag []string
+var excludeFlag []string
+
+// addEnvFlags adds the flags that control the environment of the terminal and exec commands
+func addEnvFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&cleanEnvFlag, "clean-env", false, "Start from an empty environment instead of the one psenv runs in")
+	cmd.Flags().StringSliceVar(&keepEnvFlag, "keep-env", nil, "With --clean-env, keep these inherited variables, e.g. PATH,HOME")
+	cmd.Flags().StringVar(&preferFlag, "prefer", terminal.PreferRemote, "Which value wins when a parameter is also set in the environment, local or remote")
+	cmd.Flags().StringSliceVar(&onlyFlag, "only", nil, "Only set these parameters, e.g. KEY1,KEY2")
+	cmd.Flags().StringSliceVar(&excludeFlag, "exclude", nil, "Set every parameter except these")
+}
 
 func init() {
 	rootCmd.AddCommand(terminalCmd)
 	terminalCmd.Flags().BoolVar(&NoDecryptFlag, "no-decrypt", true, "Do not decrypt secure string parameters")
 	terminalCmd.Flags().StringVarP(&terminalEnvName, "env", "e", "", "The environment to start a terminal session with")
+	addEnvFlags(terminalCmd)
 
 	rootCmd.AddCommand(execCmd)
 	execCmd.Flags().BoolVar(&NoDecryptFlag, "no-decrypt", true, "Do not decrypt secure string parameters")
 	execCmd.Flags().StringVarP(&terminalEnvName, "env", "e", "", "The environment to start a terminal session with")
+	addEnvFlags(execCmd)
+	execCmd.Flags().BoolVar(&execShellFlag, "shell", false, "Run the command through $SHELL -c instead of directly")
+	execCmd.Flags().BoolVar(&watchFlag, "watch", false, "Keep polling the parameters and restart or signal the command when they change")
+	execCmd.Flags().DurationVar(&watchIntervalFlag, "interval", 60*time.Second, "How often to poll the parameters with --watch")
+	execCmd.Flags().StringVar(&watchSignalFlag, "signal", "", "Send this signal, e.g. HUP, instead of restarting the command when parameters change")
+	execCmd.Flags().StringVar(&watchEnvFileFlag, "env-file", "", "With --signal, write the changed environment to this file. Its path is passed to the command in "+terminal.EnvFileVar)
+	execCmd.Flags().DurationVar(&shutdownTimeoutFlag, "shutdown-timeout", terminal.DefaultShutdownTimeout, "How long the command gets to exit after SIGTERM before it is killed on restart")
 }
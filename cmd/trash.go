@@ -0,0 +1,238 @@
+/*
+Copyright © 2024 Jesse Maitland jesse@pytoolbelt.com
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/pytoolbelt/psenv/internal/trash"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+	"time"
+)
+
+var noTrashFlag bool
+var trashEnvName string
+var trashKeysFlag []string
+var trashOverwriteFlag bool
+var trashOlderThanFlag time.Duration
+var trashAllFlag bool
+var trashKeyIDFlag string
+
+// addTrashFlag adds the flag that skips the trash for commands that delete parameters
+func addTrashFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noTrashFlag, "no-trash", false, "Delete parameters for good instead of moving them to the trash")
+}
+
+// trashDeleted returns the hook that moves parameters to the trash before they are
+// deleted, or nil with --no-trash. Every delete of one command goes into one batch.
+func trashDeleted(trashPath string, keyID func(env string) string) func(ctx context.Context, ps *parameterstore.ParameterStore, env, path string, params map[string]parameterstore.Parameter) (func(context.Context) error, error) {
+	if noTrashFlag {
+		return nil
+	}
+
+	batch := trash.Batch(time.Now())
+	return func(ctx context.Context, ps *parameterstore.ParameterStore, env, path string, params map[string]parameterstore.Parameter) (func(context.Context) error, error) {
+		copies, err := trash.Put(ctx, ps, trashPath, batch, env, path, params, keyID(env))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("%s: %d parameters moved to the trash as batch %s, see psenv trash list\n", env, len(params), batch)
+
+		// a rolled back put deleted nothing, so its copies are purged again
+		return func(ctx context.Context) error {
+			return trash.Purge(ctx, ps, copies)
+		}, nil
+	}
+}
+
+// loadTrash loads the project config and the trash of an environment
+func loadTrash(ctx context.Context, env string) (*config.ProjectConfig, *parameterstore.ParameterStore, []trash.Entry) {
+	projectConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		fmt.Printf("error loading project config %s\n", err)
+		os.Exit(1)
+	}
+
+	// a deleted environment is read with the settings delete kept for it
+	ps, err := parameterstore.New(clientOptions(projectConfig, env)...)
+	if err != nil {
+		fmt.Printf("error creating ssm paramstore %s\n", err)
+		os.Exit(1)
+	}
+
+	entries, err := trash.List(ctx, ps, projectConfig.GetTrashPath(), env)
+	if err != nil {
+		fmt.Printf("error listing the trash %s\n", err)
+		os.Exit(1)
+	}
+	return projectConfig, ps, entries
+}
+
+func trashListEntryPoint(cmd *cobra.Command, args []string) {
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	_, _, entries := loadTrash(ctx, trashEnvName)
+	if len(entries) == 0 {
+		fmt.Printf("The trash of %s is empty\n", trashEnvName)
+		os.Exit(0)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Batch", "Key", "Type", "Version"})
+	for _, entry := range entries {
+		table.Append([]string{entry.Batch, entry.Key, string(entry.Param.Type), strconv.FormatInt(entry.Param.Version, 10)})
+	}
+	table.Render()
+	os.Exit(0)
+}
+
+func trashRestoreEntryPoint(cmd *cobra.Command, args []string) {
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	projectConfig, ps, entries := loadTrash(ctx, trashEnvName)
+	selected := trash.Select(entries, args[0], time.Time{})
+	if len(trashKeysFlag) > 0 {
+		var keys []trash.Entry
+		for _, entry := range selected {
+			for _, key := range trashKeysFlag {
+				if entry.Key == key {
+					keys = append(keys, entry)
+				}
+			}
+		}
+		selected = keys
+	}
+
+	if len(selected) == 0 {
+		fmt.Printf("Nothing in batch %s of the trash of %s to restore\n", args[0], trashEnvName)
+		os.Exit(1)
+	}
+
+	keyID := trashKeyIDFlag
+	if !cmd.Flags().Changed("kms-name") {
+		keyID = projectConfig.GetKMSKeyID(trashEnvName, trashKeyIDFlag)
+	}
+
+	// the environment may have been deleted, its path doesn't depend on the project config
+	restored, err := trash.Restore(ctx, ps, selected, projectConfig.ExpandEnvironmentPath(trashEnvName), keyID, trashOverwriteFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// a deleted environment comes back with the settings it was deleted with
+	if !projectConfig.HasEnvironment(trashEnvName) {
+		projectConfig.AddEnvironment(trashEnvName)
+		if err := projectConfig.Save(); err != nil {
+			fmt.Printf("error saving project config %s\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Restored %d parameters to %s, run psenv get to update %s\n", len(restored), trashEnvName, config.SecretsConfigFile)
+	os.Exit(0)
+}
+
+func trashPurgeEntryPoint(cmd *cobra.Command, args []string) {
+	batch := ""
+	if len(args) > 0 {
+		batch = args[0]
+	}
+	if batch == "" && trashOlderThanFlag <= 0 && !trashAllFlag {
+		fmt.Println("Please specify a batch, --older-than or --all.")
+		os.Exit(1)
+	}
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	projectConfig, ps, entries := loadTrash(ctx, trashEnvName)
+
+	var before time.Time
+	if trashOlderThanFlag > 0 {
+		before = time.Now().Add(-trashOlderThanFlag)
+	}
+	selected := trash.Select(entries, batch, before)
+	if len(selected) == 0 {
+		fmt.Println("Nothing to purge")
+		os.Exit(0)
+	}
+
+	names := make([]string, 0, len(selected))
+	for _, entry := range selected {
+		names = append(names, entry.Param.Name)
+	}
+	if err := confirmDelete(projectConfig)(trashEnvName, names); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := trash.Purge(ctx, ps, selected); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// once the trash of a deleted environment is empty its settings aren't needed
+	if !projectConfig.HasEnvironment(trashEnvName) && len(selected) == len(entries) {
+		projectConfig.ForgetDeletedEnvironment(trashEnvName)
+		if err := projectConfig.Save(); err != nil {
+			fmt.Printf("error saving project config %s\n", err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(0)
+}
+
+// trashCmd represents the trash command
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List, restore and purge deleted parameters",
+	Long: `Parameters deleted by put and delete are first moved to the trash of their environment,
+in batches named after the time of the delete. They stay there until they are restored or purged.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the deleted parameters of an environment",
+	Long:  ``,
+	Args:  cobra.NoArgs,
+	Run:   trashListEntryPoint,
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <batch>",
+	Short: "Put the parameters of a batch back in their environment",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run:   trashRestoreEntryPoint,
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge [batch]",
+	Short: "Delete parameters from the trash for good",
+	Long:  ``,
+	Args:  cobra.MaximumNArgs(1),
+	Run:   trashPurgeEntryPoint,
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd)
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashPurgeCmd)
+	trashCmd.PersistentFlags().StringVarP(&trashEnvName, "env", "e", "", "The environment whose trash to use")
+	trashCmd.MarkPersistentFlagRequired("env")
+
+	trashRestoreCmd.Flags().StringSliceVar(&trashKeysFlag, "key", nil, "Only restore these keys of the batch")
+	trashRestoreCmd.Flags().BoolVar(&trashOverwriteFlag, "overwrite", false, "Replace parameters that exist again")
+	trashRestoreCmd.Flags().StringVarP(&trashKeyIDFlag, "kms-name", "k", "alias/aws/ssm", "KMS key name to use for encryption")
+
+	trashPurgeCmd.Flags().DurationVar(&trashOlderThanFlag, "older-than", 0, "Purge every batch deleted longer ago than this, e.g. 720h")
+	trashPurgeCmd.Flags().BoolVar(&trashAllFlag, "all", false, "Purge every batch")
+	addConfirmFlags(trashPurgeCmd)
+}
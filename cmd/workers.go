@@ -1,67 +1,47 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/engine"
 	"github.com/pytoolbelt/psenv/internal/parameterstore"
 	"os"
-	"sync"
+	"strings"
 )
 
-func putWorker(paramsToAdd map[string]string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	// put the parameters in the parameter store
-	ps, err := parameterstore.New()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-
-	// put the parameters in the parameter store
-	err = ps.PutParameters(paramsToAdd, keyIDFlag, overwriteFlag)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+// newEngine returns a sync engine that talks to each environment with the account
+// settings from the project config and finds its parameters with path
+func newEngine(projectConfig *config.ProjectConfig, path func(env string) string) *engine.Engine {
+	return &engine.Engine{
+		NewClient: func(env string) (*parameterstore.ParameterStore, error) {
+			return parameterstore.New(clientOptions(projectConfig, env)...)
+		},
+		Path:    path,
+		Workers: workersFlag,
+		Out:     os.Stdout,
 	}
 }
 
-func deleteWorker(paramsToDelete []string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	// delete the parameters in the parameter store
-	ps, err := parameterstore.New()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+// exitIfCancelled prints which environments finished and exits when the context was
+// cancelled by Ctrl-C or --timeout. It does nothing while the context is still live.
+func exitIfCancelled(ctx context.Context, results engine.Results) {
+	if ctx.Err() == nil {
+		return
 	}
 
-	// delete the parameters in the parameter store
-	err = ps.DeleteParameters(paramsToDelete)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	reason := "interrupted"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = fmt.Sprintf("timed out after %s", timeoutFlag)
 	}
+	fmt.Printf("%s, finished environments: %s, not finished: %s\n", reason, listOrNone(results.Finished()), listOrNone(results.Unfinished()))
+	os.Exit(1)
 }
 
-func getWorker(envChan <-chan string, wg *sync.WaitGroup, paramsChan chan<- map[string]string, secretsConfig *config.SecretsConfig) {
-	defer wg.Done()
-
-	// get the parameter store. If we can't make one for some reason,
-	// just exit as there is nothing to do.
-	ps, err := parameterstore.New()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-
-	for env := range envChan {
-		// get the parameters for a given environment path
-		path := secretsConfig.GetEnvironmentPath(env)
-		remoteParams, err := ps.GetParameters(path, false)
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-
-		paramsChan <- remoteParams
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
 	}
+	return strings.Join(values, ", ")
 }
@@ -0,0 +1,217 @@
+// Package adopt brings parameters that were created by hand or by other tools under
+// psenv. A tree is scanned, its environments and keys are inferred, and the project
+// and secrets configs are generated from them. Parameters that do not sit where psenv
+// expects them can be moved into place.
+package adopt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+)
+
+// BaseEnvironment holds the parameters found directly on the scanned path
+const BaseEnvironment = "base"
+
+// Move is a parameter that is only adopted once it is moved to where psenv expects it
+type Move struct {
+	Env  string
+	Key  string
+	From string
+	To   string
+}
+
+// Plan is what adopting a tree does
+type Plan struct {
+	// Path is the scanned path
+	Path string
+
+	// Prefix and Project are where the adopted environments live
+	Prefix  string
+	Project string
+
+	// Environments holds the adopted parameters by environment and key, as they
+	// were read from the scanned path
+	Environments map[string]map[string]parameterstore.Parameter
+
+	// Moves lists the parameters that have to be moved to be adopted. Unless Move
+	// is set they are skipped instead.
+	Moves []Move
+	Move  bool
+}
+
+// Target returns the prefix and project a tree is adopted as when it is not moved.
+// /legacy/app becomes prefix /legacy and project app.
+func Target(scanned string) (string, string) {
+	scanned = path.Clean("/" + scanned)
+	prefix := path.Dir(scanned)
+	if prefix == "/" {
+		prefix = ""
+	}
+	return prefix, path.Base(scanned)
+}
+
+// NewPlan infers the environments and keys of the parameters below a path. The first
+// level below the path is the environment, parameters directly on the path go to base.
+// Parameters that don't end up at their current name are moved when move is set,
+// otherwise they are skipped.
+func NewPlan(scanned, prefix, project string, params map[string]parameterstore.Parameter, move bool) (*Plan, error) {
+	plan := &Plan{
+		Path:         strings.TrimSuffix(scanned, "/"),
+		Prefix:       prefix,
+		Project:      project,
+		Environments: make(map[string]map[string]parameterstore.Parameter),
+		Move:         move,
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	target := &config.ProjectConfig{Prefix: prefix, Project: project}
+	sources := make(map[string]string)
+	var collisions []string
+
+	for _, name := range names {
+		relative, ok := config.RelativeKey(plan.Path, name)
+		if !ok {
+			return nil, fmt.Errorf("parameter %s is not below %s", name, plan.Path)
+		}
+
+		env, key, nested := strings.Cut(relative, config.KeySeparator)
+		if !nested {
+			env, key = BaseEnvironment, relative
+		}
+		if env == config.TrashEnvironment {
+			// deleted parameters stay in the trash
+			continue
+		}
+		key = config.SecretKey(key)
+
+		id := env + config.KeySeparator + key
+		if other, ok := sources[id]; ok {
+			collisions = append(collisions, fmt.Sprintf("%s and %s are both key %s of %s", other, name, key, env))
+			continue
+		}
+		sources[id] = name
+
+		if !target.HasEnvironment(env) {
+			target.Environments = append(target.Environments, config.Environment{Name: env})
+		}
+		to := target.GetEnvironmentPath(env) + config.KeySeparator + key
+		if to != name {
+			plan.Moves = append(plan.Moves, Move{Env: env, Key: key, From: name, To: to})
+			if !move {
+				continue
+			}
+		}
+
+		if _, ok := plan.Environments[env]; !ok {
+			plan.Environments[env] = make(map[string]parameterstore.Parameter)
+		}
+		plan.Environments[env][key] = params[name]
+	}
+
+	if len(collisions) > 0 {
+		return nil, fmt.Errorf("parameters that can't be told apart once adopted: %s", strings.Join(collisions, "; "))
+	}
+	return plan, nil
+}
+
+// EnvironmentNames returns the adopted environments, base first and then sorted. Base
+// is always included as every psenv project has one.
+func (p *Plan) EnvironmentNames() []string {
+	names := []string{BaseEnvironment}
+	for env := range p.Environments {
+		if env != BaseEnvironment {
+			names = append(names, env)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// ProjectConfig returns the project config of the adopted tree
+func (p *Plan) ProjectConfig() *config.ProjectConfig {
+	projectConfig := &config.ProjectConfig{Prefix: p.Prefix, Project: p.Project}
+	for _, env := range p.EnvironmentNames() {
+		projectConfig.Environments = append(projectConfig.Environments, config.Environment{Name: env})
+	}
+
+	// the first environment after base is what most people work in
+	projectConfig.Default = BaseEnvironment
+	if names := p.EnvironmentNames(); len(names) > 1 {
+		projectConfig.Default = names[1]
+	}
+	return projectConfig
+}
+
+// SecretsConfig returns the secrets config holding the adopted parameters
+func (p *Plan) SecretsConfig() *config.SecretsConfig {
+	secretsConfig := &config.SecretsConfig{
+		Project:      p.Project,
+		Prefix:       p.Prefix,
+		Environments: make(map[string]config.Secrets),
+	}
+	for env, params := range p.Environments {
+		secrets := make(config.Secrets)
+		for key, param := range params {
+			secrets[key] = config.NewSecretFromParameter(param)
+		}
+		secretsConfig.Environments[env] = secrets
+	}
+	return secretsConfig
+}
+
+// Print writes a summary of the plan
+func (p *Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "adopting %s as project %s with prefix %q\n", p.Path, p.Project, p.Prefix)
+	for _, env := range p.EnvironmentNames() {
+		fmt.Fprintf(w, "  %s: %d parameters\n", env, len(p.Environments[env]))
+	}
+
+	for _, move := range p.Moves {
+		if !p.Move {
+			fmt.Fprintf(w, "skip %s, it has to be moved to %s to be adopted (use --move)\n", move.From, move.To)
+		} else {
+			fmt.Fprintf(w, "move %s -> %s\n", move.From, move.To)
+		}
+	}
+}
+
+// Apply moves the parameters of the plan. Every parameter is first written to its new
+// name and the old ones are only deleted once all of them were written, so a failure
+// never loses a value. SecureStrings are encrypted with keyID.
+func (p *Plan) Apply(ctx context.Context, ps *parameterstore.ParameterStore, keyID string) error {
+	if !p.Move || len(p.Moves) == 0 {
+		return nil
+	}
+
+	secretsConfig := p.SecretsConfig()
+	envParams := make(map[string]map[string]parameterstore.Parameter)
+	moved := make(map[string]parameterstore.Parameter, len(p.Moves))
+	from := make([]string, 0, len(p.Moves))
+	for _, move := range p.Moves {
+		if _, ok := envParams[move.Env]; !ok {
+			envParams[move.Env] = secretsConfig.GetEnvironmentParams(move.Env)
+		}
+		moved[move.To] = envParams[move.Env][move.To]
+		from = append(from, move.From)
+	}
+
+	if _, err := ps.PutParameters(ctx, moved, keyID, false); err != nil {
+		return fmt.Errorf("error writing moved parameters, the originals were kept: %w", err)
+	}
+	if err := ps.DeleteParameters(ctx, from); err != nil {
+		return fmt.Errorf("error deleting moved parameters from their old names: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package adopt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pytoolbelt/psenv/internal/devserver/devservertest"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarget(t *testing.T) {
+	prefix, project := Target("/legacy/app")
+	require.Equal(t, "/legacy", prefix)
+	require.Equal(t, "app", project)
+
+	prefix, project = Target("/app/")
+	require.Equal(t, "", prefix)
+	require.Equal(t, "app", project)
+}
+
+func TestNewPlanInfersEnvironments(t *testing.T) {
+	params := map[string]parameterstore.Parameter{
+		"/legacy/app/dev/DB_URL":         {Value: "dev-db"},
+		"/legacy/app/dev/db/PASSWORD":    {Value: "secret"},
+		"/legacy/app/prod/DB_URL":        {Value: "prod-db"},
+		"/legacy/app/prod/api_key":       {Value: "key"},
+		"/legacy/app/LOG_LEVEL":          {Value: "info"},
+		"/legacy/app/staging/cache/HOST": {Value: "cache"},
+	}
+
+	plan, err := NewPlan("/legacy/app", "/legacy", "app", params, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"base", "dev", "prod", "staging"}, plan.EnvironmentNames())
+	require.Equal(t, "dev-db", plan.Environments["dev"]["DB_URL"].Value)
+	require.Equal(t, "secret", plan.Environments["dev"]["db/PASSWORD"].Value)
+	require.Equal(t, "cache", plan.Environments["staging"]["cache/HOST"].Value)
+
+	// LOG_LEVEL is not below an environment and api_key is renamed by psenv
+	require.Empty(t, plan.Environments["base"])
+	require.NotContains(t, plan.Environments["prod"], "API_KEY")
+	require.Equal(t, []Move{
+		{Env: "base", Key: "LOG_LEVEL", From: "/legacy/app/LOG_LEVEL", To: "/legacy/app/base/LOG_LEVEL"},
+		{Env: "prod", Key: "API_KEY", From: "/legacy/app/prod/api_key", To: "/legacy/app/prod/API_KEY"},
+	}, plan.Moves)
+
+	projectConfig := plan.ProjectConfig()
+	require.Equal(t, "dev", projectConfig.Default)
+	require.Equal(t, "/legacy/app/dev", projectConfig.GetEnvironmentPath("dev"))
+
+	secretsConfig := plan.SecretsConfig()
+	require.Contains(t, secretsConfig.GetEnvironmentParams("dev"), "/legacy/app/dev/db/PASSWORD")
+}
+
+func TestNewPlanRejectsCollisions(t *testing.T) {
+	_, err := NewPlan("/legacy/app", "/legacy", "app", map[string]parameterstore.Parameter{
+		"/legacy/app/dev/KEY": {Value: "upper"},
+		"/legacy/app/dev/key": {Value: "lower"},
+	}, true)
+	require.ErrorContains(t, err, "/legacy/app/dev/KEY and /legacy/app/dev/key")
+}
+
+func TestApplyMovesParameters(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+
+	ctx := context.Background()
+	_, err := ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		"/legacy/app/LOG_LEVEL":  {Value: "info", Type: "String", Description: "how chatty"},
+		"/legacy/app/dev/DB_URL": {Value: "dev-db"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	params, err := ps.GetParametersWithMetadata(ctx, "/legacy/app", true)
+	require.NoError(t, err)
+
+	plan, err := NewPlan("/legacy/app", "/apps", "app", params, true)
+	require.NoError(t, err)
+	require.Len(t, plan.Moves, 2)
+	require.NoError(t, plan.Apply(ctx, ps, "alias/aws/ssm"))
+
+	remaining, err := ps.GetParameters(ctx, "/legacy/app", true)
+	require.NoError(t, err)
+	require.Empty(t, remaining)
+
+	moved, err := ps.GetParametersWithMetadata(ctx, "/apps/app", true)
+	require.NoError(t, err)
+	require.Equal(t, "info", moved["/apps/app/base/LOG_LEVEL"].Value)
+	require.Equal(t, "how chatty", moved["/apps/app/base/LOG_LEVEL"].Description)
+	require.Equal(t, "dev", moved["/apps/app/dev/DB_URL"].Tags[parameterstore.EnvironmentTagKey])
+	require.Equal(t, "dev-db", moved["/apps/app/dev/DB_URL"].Value)
+}
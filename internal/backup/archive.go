@@ -0,0 +1,207 @@
+// Package backup writes every environment of a project to a single encrypted archive
+// and replays it later, into the same project or under another prefix, project,
+// account or endpoint. Archives are sealed with a passphrase, see Seal.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/engine"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+)
+
+// FormatVersion is the version of the archive contents written by this psenv
+const FormatVersion = 1
+
+// Archive is the decrypted contents of a backup
+type Archive struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// where the environments were backed up from
+	Project      string              `json:"project"`
+	Prefix       string              `json:"prefix"`
+	PathTemplate config.PathTemplate `json:"path_template,omitempty"`
+	PathVars     map[string]string   `json:"path_vars,omitempty"`
+
+	// Environments holds the parameters of every environment by their key below the
+	// environment path. Names and versions are kept for reference only, a restore
+	// writes to the target path and the parameter store numbers versions itself.
+	Environments map[string]map[string]parameterstore.Parameter `json:"environments"`
+}
+
+// New creates an archive from the decrypted parameters and metadata of every
+// environment. A backup that misses an environment is no backup, so any failed
+// environment is an error.
+func New(projectConfig *config.ProjectConfig, results engine.Results, at time.Time) (*Archive, error) {
+	if err := results.Err(); err != nil {
+		return nil, err
+	}
+
+	archive := &Archive{
+		FormatVersion: FormatVersion,
+		CreatedAt:     at.UTC(),
+		Project:       projectConfig.Project,
+		Prefix:        projectConfig.Prefix,
+		PathTemplate:  projectConfig.PathTemplate,
+		PathVars:      projectConfig.PathVars,
+		Environments:  make(map[string]map[string]parameterstore.Parameter, len(results)),
+	}
+
+	for _, result := range results {
+		params := make(map[string]parameterstore.Parameter, len(result.Params))
+		for name, param := range result.Params {
+			key, ok := config.RelativeKey(result.Path, name)
+			if !ok {
+				return nil, fmt.Errorf("parameter %s is not below %s", name, result.Path)
+			}
+			params[key] = param
+		}
+		archive.Environments[result.Env] = params
+	}
+	return archive, nil
+}
+
+// EnvironmentNames returns the environments in the archive, sorted
+func (a *Archive) EnvironmentNames() []string {
+	envs := make([]string, 0, len(a.Environments))
+	for env := range a.Environments {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+// ProjectConfig returns the project config the archive was taken from, without the
+// account details of its environments
+func (a *Archive) ProjectConfig() *config.ProjectConfig {
+	projectConfig := &config.ProjectConfig{
+		Project:      a.Project,
+		Prefix:       a.Prefix,
+		PathTemplate: a.PathTemplate,
+		PathVars:     a.PathVars,
+	}
+	for _, env := range a.EnvironmentNames() {
+		projectConfig.AddEnvironment(env)
+	}
+	return projectConfig
+}
+
+// Target returns the project config to restore into. It is base, e.g. the project
+// config of the target account, moved to where the archive was taken from and with
+// the archived environments added, so environments keep their account details.
+// Without a base it is the project config the archive was taken from.
+func (a *Archive) Target(base *config.ProjectConfig) *config.ProjectConfig {
+	if base == nil {
+		return a.ProjectConfig()
+	}
+
+	target := *base
+	target.Environments = slices.Clone(base.Environments)
+	target.Project = a.Project
+	target.Prefix = a.Prefix
+	target.PathTemplate = a.PathTemplate
+	target.PathVars = a.PathVars
+	for _, env := range a.EnvironmentNames() {
+		target.AddEnvironment(env)
+	}
+	return &target
+}
+
+// Params returns the parameters of the environments to restore, named after where
+// the target project config puts them. The psenv project and environment tags are
+// rewritten to match the target.
+func (a *Archive) Params(target *config.ProjectConfig, envs []string) (map[string]map[string]parameterstore.Parameter, error) {
+	local := make(map[string]map[string]parameterstore.Parameter, len(envs))
+	for _, env := range envs {
+		params, ok := a.Environments[env]
+		if !ok {
+			return nil, fmt.Errorf("environment %s is not in the backup, it has %v", env, a.EnvironmentNames())
+		}
+
+		path := target.GetEnvironmentPath(env)
+		if path == "" {
+			return nil, fmt.Errorf("environment %s is not in the target project config", env)
+		}
+		local[env] = make(map[string]parameterstore.Parameter, len(params))
+		for key, param := range params {
+			param.Name = path + config.KeySeparator + key
+			param.Version = 0
+			param.Tags = retag(param.Tags, target.Project, env)
+			local[env][param.Name] = param
+		}
+	}
+	return local, nil
+}
+
+// retag copies tags with the psenv project and environment tags pointed at the target
+func retag(tags map[string]string, project, env string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+
+	retagged := make(map[string]string, len(tags))
+	for k, v := range tags {
+		retagged[k] = v
+	}
+	if _, ok := retagged[parameterstore.ProjectTagKey]; ok {
+		retagged[parameterstore.ProjectTagKey] = project
+	}
+	if _, ok := retagged[parameterstore.EnvironmentTagKey]; ok {
+		retagged[parameterstore.EnvironmentTagKey] = env
+	}
+	return retagged
+}
+
+// Write seals the archive with the passphrase and writes it
+func Write(w io.Writer, archive *Archive, passphrase string) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(archive); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	sealed, err := Seal(buf.Bytes(), passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(sealed)
+	return err
+}
+
+// Read opens an archive sealed with the passphrase
+func Read(r io.Reader, passphrase string) (*Archive, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := Open(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("backup is corrupt: %w", err)
+	}
+	var archive Archive
+	if err := json.NewDecoder(zr).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("backup is corrupt: %w", err)
+	}
+	if archive.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("backup has format version %d, this psenv reads up to %d", archive.FormatVersion, FormatVersion)
+	}
+	return &archive, nil
+}
@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/devserver/devservertest"
+	"github.com/pytoolbelt/psenv/internal/engine"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		keyLen         int
+		want           string
+	}{
+		// RFC 7914, section 11
+		{"passwd", "salt", 1, 64, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, 64, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+			"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+		// a single block, with the 4096 iterations of the RFC 6070 vectors
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+	for _, tt := range tests {
+		key := pbkdf2([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen, sha256.New)
+		require.Equal(t, tt.want, hex.EncodeToString(key), "%s/%s c=%d", tt.password, tt.salt, tt.iterations)
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	sealed, err := Seal([]byte("secret"), "correct horse")
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed), "secret")
+
+	data, err := Open(sealed, "correct horse")
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(data))
+
+	_, err = Open(sealed, "wrong horse")
+	require.ErrorContains(t, err, "wrong passphrase")
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	_, err = Open(tampered, "correct horse")
+	require.ErrorContains(t, err, "wrong passphrase")
+
+	_, err = Open([]byte("PK\x03\x04"), "correct horse")
+	require.ErrorContains(t, err, "not a psenv backup")
+
+	_, err = Seal([]byte("secret"), "")
+	require.ErrorContains(t, err, "passphrase is required")
+}
+
+func TestBackupAndRestoreToAnotherPrefix(t *testing.T) {
+	url := devservertest.Start(t, "")
+
+	newEngine := func(projectConfig *config.ProjectConfig) *engine.Engine {
+		return &engine.Engine{
+			NewClient: func(env string) (*parameterstore.ParameterStore, error) {
+				return parameterstore.New(devservertest.ClientOptions(url)...)
+			},
+			Path: projectConfig.GetEnvironmentPath,
+		}
+	}
+
+	ctx := context.Background()
+	source := &config.ProjectConfig{Project: "foobar", Prefix: "/psenv"}
+	source.AddEnvironment("dev")
+	source.AddEnvironment("prod")
+
+	results := newEngine(source).Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "value1", Type: "String", Description: "first"},
+			"/psenv/foobar/dev/db/PASSWORD": {Value: "secret", Tags: map[string]string{
+				parameterstore.ProjectTagKey:     "foobar",
+				parameterstore.EnvironmentTagKey: "dev",
+				"team":                           "platform",
+			}},
+		},
+		"prod": {"/psenv/foobar/prod/KEY1": {Value: "prod"}},
+	}, engine.PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+
+	results = newEngine(source).Get(ctx, source.EnvironmentNames(), engine.GetOptions{Decrypt: true, WithMetadata: true})
+	archive, err := New(source, results, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev", "prod"}, archive.EnvironmentNames())
+	require.Equal(t, "secret", archive.Environments["dev"]["db/PASSWORD"].Value)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, archive, "correct horse"))
+	restored, err := Read(&buf, "correct horse")
+	require.NoError(t, err)
+	require.Equal(t, archive, restored)
+
+	restored.Prefix = "/restored"
+	target := restored.Target(nil)
+	local, err := restored.Params(target, []string{"dev"})
+	require.NoError(t, err)
+	results = newEngine(target).Put(ctx, local, engine.PutOptions{})
+	require.NoError(t, results.Err())
+
+	params := results[0].Params
+	require.Len(t, params, 2)
+	require.Equal(t, "secret", params["/restored/foobar/dev/db/PASSWORD"].Value)
+	require.Equal(t, "first", params["/restored/foobar/dev/KEY1"].Description)
+	require.Equal(t, "platform", params["/restored/foobar/dev/db/PASSWORD"].Tags["team"])
+	require.Equal(t, "dev", params["/restored/foobar/dev/db/PASSWORD"].Tags[parameterstore.EnvironmentTagKey])
+
+	_, err = restored.Params(target, []string{"staging"})
+	require.ErrorContains(t, err, "staging is not in the backup")
+}
+
+func TestTargetKeepsAccountDetails(t *testing.T) {
+	archive := &Archive{Project: "foobar", Prefix: "/psenv", Environments: map[string]map[string]parameterstore.Parameter{
+		"dev":  {},
+		"prod": {},
+	}}
+
+	base := &config.ProjectConfig{Project: "other", Prefix: "/other", Environments: []config.Environment{
+		{Name: "prod", Profile: "dr-account", KMSKeyID: "alias/dr"},
+	}}
+	target := archive.Target(base)
+	require.Equal(t, "/psenv/foobar/prod", target.GetEnvironmentPath("prod"))
+	require.Equal(t, "alias/dr", target.GetKMSKeyID("prod", "alias/aws/ssm"))
+	require.True(t, target.HasEnvironment("dev"))
+	require.False(t, base.HasEnvironment("dev"))
+}
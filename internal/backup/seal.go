@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// PassphraseEnvVar holds the passphrase of backups when no passphrase file is given
+const PassphraseEnvVar = "PSENV_BACKUP_PASSPHRASE"
+
+// Iterations is how many PBKDF2-HMAC-SHA256 rounds derive the key of new backups
+const Iterations = 600_000
+
+// a sealed backup is the header followed by the AES-256-GCM ciphertext, which
+// authenticates the header as well:
+//
+//	magic (8) | version (1) | iterations (4, big endian) | salt (16) | nonce (12)
+const (
+	magic      = "PSENVBAK"
+	sealFormat = 1
+	saltSize   = 16
+	keySize    = 32
+	headerSize = len(magic) + 1 + 4 + saltSize
+
+	// maxIterations keeps a crafted file from keeping Open busy for hours
+	maxIterations = 100 * Iterations
+)
+
+// Seal encrypts data with a key derived from the passphrase
+func Seal(data []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("a backup passphrase is required")
+	}
+
+	header := make([]byte, headerSize, headerSize+12)
+	copy(header, magic)
+	header[len(magic)] = sealFormat
+	binary.BigEndian.PutUint32(header[len(magic)+1:], Iterations)
+	salt := header[len(magic)+5:]
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(passphrase, salt, Iterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header = append(header, nonce...)
+	return gcm.Seal(header, nonce, data, header), nil
+}
+
+// Open decrypts data sealed with Seal. A wrong passphrase and a tampered backup
+// can't be told apart, both fail authentication.
+func Open(sealed []byte, passphrase string) ([]byte, error) {
+	if len(sealed) < headerSize || string(sealed[:len(magic)]) != magic {
+		return nil, errors.New("not a psenv backup")
+	}
+	if version := sealed[len(magic)]; version != sealFormat {
+		return nil, fmt.Errorf("backup is sealed with format %d, this psenv opens format %d", version, sealFormat)
+	}
+
+	iterations := binary.BigEndian.Uint32(sealed[len(magic)+1:])
+	if iterations == 0 || iterations > maxIterations {
+		return nil, fmt.Errorf("backup asks for %d key derivation rounds, which is out of range", iterations)
+	}
+	salt := sealed[len(magic)+5 : headerSize]
+	gcm, err := newGCM(passphrase, salt, int(iterations))
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < headerSize+gcm.NonceSize() {
+		return nil, errors.New("backup is truncated")
+	}
+
+	header := sealed[:headerSize+gcm.NonceSize()]
+	nonce := header[headerSize:]
+	data, err := gcm.Open(nil, nonce, sealed[len(header):], header)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or the backup was modified")
+	}
+	return data, nil
+}
+
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2([]byte(passphrase), salt, iterations, keySize, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives a key as in RFC 8018, section 5.2
+func pbkdf2(password, salt []byte, iterations, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	size := prf.Size()
+	blocks := (keyLen + size - 1) / size
+
+	key := make([]byte, 0, blocks*size)
+	counter := make([]byte, 4)
+	u := make([]byte, size)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter, uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter)
+		t := prf.Sum(nil)
+		copy(u, t)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -2,53 +2,358 @@ package config
 
 import (
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/olekukonko/tablewriter"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
 	"gopkg.in/yaml.v2"
 	"os"
 	"slices"
 	"strings"
+	"time"
 )
 
 const ProjectConfigFile = "psenv-project.yml"
 const SecretsConfigFile = "psenv-secrets.yml"
 
+// environment variables that override the endpoint of every parameter store client
+const (
+	EndpointURLEnvVar     = "PSENV_ENDPOINT_URL"
+	RegionEnvVar          = "PSENV_REGION"
+	AccessKeyIDEnvVar     = "PSENV_ACCESS_KEY_ID"
+	SecretAccessKeyEnvVar = "PSENV_SECRET_ACCESS_KEY"
+)
+
 type ProjectConfig struct {
-	Default      string   `yaml:"default"`
-	Environments []string `yaml:"environments"`
-	Prefix       string   `yaml:"prefix"`
-	Project      string   `yaml:"project"`
+	Default      string        `yaml:"default"`
+	Environments []Environment `yaml:"environments"`
+	Prefix       string        `yaml:"prefix"`
+	Project      string        `yaml:"project"`
+	Endpoint     *Endpoint     `yaml:"endpoint,omitempty"`
+	Retry        *Retry        `yaml:"retry,omitempty"`
+	Naming       *Naming       `yaml:"naming,omitempty"`
+
+	// PathTemplate overrides where the parameters of every environment live, the
+	// default is {prefix}/{project}/{env}. PathVars holds the values of any other
+	// placeholders it uses:
+	//
+	//	path_template: /{env}/{team}/{project}
+	//	path_vars:
+	//	  team: platform
+	PathTemplate PathTemplate      `yaml:"path_template,omitempty"`
+	PathVars     map[string]string `yaml:"path_vars,omitempty"`
+
+	// DeletedEnvironments keeps the settings of environments that were deleted into the
+	// trash, so their trash is read in the right account and a restore puts them back as
+	// they were
+	DeletedEnvironments []Environment `yaml:"deleted_environments,omitempty"`
+}
+
+// Validate checks the parts of the project config that can be wrong without failing to parse
+func (c *ProjectConfig) Validate() error {
+	if err := c.Naming.Validate(); err != nil {
+		return err
+	}
+	if err := validatePathVars(c.PathTemplate, c.PathVars); err != nil {
+		return err
+	}
+	if c.HasEnvironment(TrashEnvironment) {
+		return fmt.Errorf("%s can't be used as an environment name, it is where deleted parameters are kept", TrashEnvironment)
+	}
+	return nil
+}
+
+// Retry configures how requests that are throttled or fail with a transient error are retried:
+//
+//	retry:
+//	  mode: adaptive
+//	  max_attempts: 10
+//	  max_backoff: 30s
+type Retry struct {
+	Mode        string
+	MaxAttempts int
+	MaxBackoff  time.Duration
+}
+
+type retryFields struct {
+	Mode        string `yaml:"mode,omitempty"`
+	MaxAttempts int    `yaml:"max_attempts,omitempty"`
+	MaxBackoff  string `yaml:"max_backoff,omitempty"`
+}
+
+func (r *Retry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var fields retryFields
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+
+	if err := parameterstore.ValidateRetryMode(fields.Mode); err != nil {
+		return err
+	}
+	if fields.MaxAttempts < 0 {
+		return fmt.Errorf("retry max_attempts cannot be negative")
+	}
+
+	var maxBackoff time.Duration
+	if fields.MaxBackoff != "" {
+		var err error
+		maxBackoff, err = time.ParseDuration(fields.MaxBackoff)
+		if err != nil {
+			return fmt.Errorf("invalid retry max_backoff %q: %s", fields.MaxBackoff, err)
+		}
+	}
+
+	*r = Retry{Mode: fields.Mode, MaxAttempts: fields.MaxAttempts, MaxBackoff: maxBackoff}
+	return nil
+}
+
+func (r Retry) MarshalYAML() (interface{}, error) {
+	fields := retryFields{Mode: r.Mode, MaxAttempts: r.MaxAttempts}
+	if r.MaxBackoff > 0 {
+		fields.MaxBackoff = r.MaxBackoff.String()
+	}
+	return fields, nil
+}
+
+// ClientOptions returns the parameter store client options for the retry settings that are set
+func (r *Retry) ClientOptions() []parameterstore.Option {
+	var opts []parameterstore.Option
+	if r == nil {
+		return opts
+	}
+	if r.Mode != "" {
+		opts = append(opts, parameterstore.WithRetryMode(r.Mode))
+	}
+	if r.MaxAttempts > 0 {
+		opts = append(opts, parameterstore.WithMaxAttempts(r.MaxAttempts))
+	}
+	if r.MaxBackoff > 0 {
+		opts = append(opts, parameterstore.WithMaxBackoff(r.MaxBackoff))
+	}
+	return opts
+}
+
+// Endpoint points every parameter store client at a custom endpoint such as LocalStack:
+//
+//	endpoint:
+//	  url: http://localhost:4566
+//	  region: us-east-1
+//	  access_key_id: test
+//	  secret_access_key: test
+type Endpoint struct {
+	URL             string `yaml:"url,omitempty"`
+	Region          string `yaml:"region,omitempty"`
+	AccessKeyID     string `yaml:"access_key_id,omitempty"`
+	SecretAccessKey string `yaml:"secret_access_key,omitempty"`
+}
+
+// EndpointFromEnv reads endpoint overrides from the PSENV_ environment variables
+func EndpointFromEnv() Endpoint {
+	return Endpoint{
+		URL:             os.Getenv(EndpointURLEnvVar),
+		Region:          os.Getenv(RegionEnvVar),
+		AccessKeyID:     os.Getenv(AccessKeyIDEnvVar),
+		SecretAccessKey: os.Getenv(SecretAccessKeyEnvVar),
+	}
+}
+
+// ClientOptions returns the parameter store client options for the endpoint. Only
+// the fields that are set are returned, so they can be layered on top of other options.
+func (e *Endpoint) ClientOptions() []parameterstore.Option {
+	var opts []parameterstore.Option
+	if e == nil {
+		return opts
+	}
+	if e.URL != "" {
+		opts = append(opts, parameterstore.WithEndpointURL(e.URL))
+	}
+	if e.Region != "" {
+		opts = append(opts, parameterstore.WithRegion(e.Region))
+	}
+	if e.AccessKeyID != "" {
+		opts = append(opts, parameterstore.WithStaticCredentials(e.AccessKeyID, e.SecretAccessKey))
+	}
+	return opts
+}
+
+// Environment is a single environment of the project. In yaml an environment can be
+// written as just its name, or as a map that sets the AWS account details to use for it:
+//
+//	environments:
+//	  - dev
+//	  - name: prod
+//	    profile: prod-admin
+//	    region: eu-west-1
+//	    role_arn: arn:aws:iam::123456789012:role/psenv
+//	    external_id: psenv
+//	    session_name: psenv-prod
+//	    mfa_serial: arn:aws:iam::123456789012:mfa/jesse
+//	    kms_key_id: alias/prod-secrets
+//	    protected: true
+//
+// Deleting a protected environment, or parameters from it, has to be confirmed.
+type Environment struct {
+	Name        string `yaml:"name"`
+	Profile     string `yaml:"profile,omitempty"`
+	Region      string `yaml:"region,omitempty"`
+	RoleARN     string `yaml:"role_arn,omitempty"`
+	ExternalID  string `yaml:"external_id,omitempty"`
+	SessionName string `yaml:"session_name,omitempty"`
+	MFASerial   string `yaml:"mfa_serial,omitempty"`
+	KMSKeyID    string `yaml:"kms_key_id,omitempty"`
+	Protected   bool   `yaml:"protected,omitempty"`
+}
+
+// environmentFields is used to (un)marshal an Environment as a map without recursing
+type environmentFields Environment
+
+func (e *Environment) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*e = Environment{Name: name}
+		return nil
+	}
+
+	var fields environmentFields
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+	if fields.Name == "" {
+		return fmt.Errorf("an environment must have a name")
+	}
+	*e = Environment(fields)
+	return nil
+}
+
+func (e Environment) MarshalYAML() (interface{}, error) {
+	if e == (Environment{Name: e.Name}) {
+		return e.Name, nil
+	}
+	return environmentFields(e), nil
+}
+
+// ClientOptions returns the options to create a parameter store client for the environment
+func (e Environment) ClientOptions() []parameterstore.Option {
+	var opts []parameterstore.Option
+	if e.Profile != "" {
+		opts = append(opts, parameterstore.WithProfile(e.Profile))
+	}
+	if e.Region != "" {
+		opts = append(opts, parameterstore.WithRegion(e.Region))
+	}
+	if e.RoleARN != "" {
+		opts = append(opts, parameterstore.WithRoleARN(e.RoleARN))
+	}
+	if e.ExternalID != "" {
+		opts = append(opts, parameterstore.WithExternalID(e.ExternalID))
+	}
+	if e.SessionName != "" {
+		opts = append(opts, parameterstore.WithSessionName(e.SessionName))
+	}
+	if e.MFASerial != "" {
+		opts = append(opts, parameterstore.WithMFA(e.MFASerial))
+	}
+	return opts
 }
 
 // PrintTable prints the project config as a table to the terminal
 func (c *ProjectConfig) PrintTable() {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetAlignment(tablewriter.ALIGN_CENTER)
-	table.SetHeader([]string{"Prefix", "Project", "Default", "Environments", "Path"})
+	table.SetHeader([]string{"Prefix", "Project", "Default", "Environments", "Path", "Profile", "Region"})
 
-	table.Append([]string{c.Prefix, c.Project, c.Default, c.Environments[0], c.GetEnvironmentPath(c.Environments[0])})
-
-	for _, env := range c.Environments[1:] {
-		table.Append([]string{"", "", "", env, c.GetEnvironmentPath(env)})
+	for i, env := range c.Environments {
+		if i == 0 {
+			table.Append([]string{c.Prefix, c.Project, c.Default, env.Name, c.GetEnvironmentPath(env.Name), env.Profile, env.Region})
+			continue
+		}
+		table.Append([]string{"", "", "", env.Name, c.GetEnvironmentPath(env.Name), env.Profile, env.Region})
 	}
 
 	table.Render()
 }
 
+// EnvironmentNames returns the names of all environments in the project
+func (c *ProjectConfig) EnvironmentNames() []string {
+	names := make([]string, 0, len(c.Environments))
+	for _, env := range c.Environments {
+		names = append(names, env.Name)
+	}
+	return names
+}
+
+// GetEnvironment returns the environment with the given name. A deleted environment
+// is returned with the settings it was deleted with, any other environment that is not
+// in the project config with just its name set.
+func (c *ProjectConfig) GetEnvironment(name string) Environment {
+	byName := func(e Environment) bool { return e.Name == name }
+	if i := slices.IndexFunc(c.Environments, byName); i != -1 {
+		return c.Environments[i]
+	}
+	if i := slices.IndexFunc(c.DeletedEnvironments, byName); i != -1 {
+		return c.DeletedEnvironments[i]
+	}
+	return Environment{Name: name}
+}
+
+// GetClientOptions returns the parameter store client options for an environment,
+// with the project endpoint and retry settings applied on top
+func (c *ProjectConfig) GetClientOptions(env string) []parameterstore.Option {
+	opts := append(c.GetEnvironment(env).ClientOptions(), c.Endpoint.ClientOptions()...)
+	return append(opts, c.Retry.ClientOptions()...)
+}
+
+// IsProtected reports whether destructive operations on an environment have to be
+// confirmed. A deleted environment stays protected, purging its trash is one of them.
+func (c *ProjectConfig) IsProtected(env string) bool {
+	return c.GetEnvironment(env).Protected
+}
+
+// GetKMSKeyID returns the KMS key configured for an environment, or the fallback if there is none
+func (c *ProjectConfig) GetKMSKeyID(env, fallback string) string {
+	if key := c.GetEnvironment(env).KMSKeyID; key != "" {
+		return key
+	}
+	return fallback
+}
+
 // GetEnvironmentPath returns the path to the environment
 func (c *ProjectConfig) GetEnvironmentPath(env string) string {
-	i := slices.Index(c.Environments, env)
-	if i == -1 {
+	if !c.HasEnvironment(env) {
 		return ""
 	}
-	return c.Prefix + "/" + c.Project + "/" + env
+	return c.ExpandEnvironmentPath(env)
+}
+
+// ExpandEnvironmentPath returns where the path template puts an environment, whether
+// or not it is in the project config, e.g. one that was deleted
+func (c *ProjectConfig) ExpandEnvironmentPath(env string) string {
+	return c.PathTemplate.OrDefault().Expand(pathVars(c.Prefix, c.Project, env, c.PathVars))
 }
 
-func (c *ProjectConfig) GetBasePath() string {
-	return fmt.Sprintf("%s/%s/", c.Prefix, c.Project)
+// GetTrashPath returns where deleted parameters are kept, the path template with
+// .trash for the environment, e.g. /prefix/project/.trash
+func (c *ProjectConfig) GetTrashPath() string {
+	return c.ExpandEnvironmentPath(TrashEnvironment)
 }
 
 func (c *ProjectConfig) HasEnvironment(env string) bool {
-	return slices.Contains(c.Environments, env)
+	return slices.Contains(c.EnvironmentNames(), env)
+}
+
+// BaseEnvironment is the environment every other environment inherits parameters from
+const BaseEnvironment = "base"
+
+// LayeredEnvironments returns the environments that make up env in the order their
+// parameters are layered, so later ones win: base when the project has one, then env
+func (c *ProjectConfig) LayeredEnvironments(env string) []string {
+	var envs []string
+	if c.HasEnvironment(BaseEnvironment) {
+		envs = append(envs, BaseEnvironment)
+	}
+	if env != BaseEnvironment {
+		envs = append(envs, env)
+	}
+	return envs
 }
 
 func (c *ProjectConfig) Save() error {
@@ -66,27 +371,318 @@ func (c *ProjectConfig) Save() error {
 }
 
 func (c *ProjectConfig) RemoveEnvironment(env string) {
-	i := slices.Index(c.Environments, env)
-	c.Environments = slices.Delete(c.Environments, i, i+1)
+	c.Environments = slices.DeleteFunc(c.Environments, func(e Environment) bool { return e.Name == env })
+}
+
+// DeleteEnvironment removes an environment whose parameters went to the trash and
+// keeps its settings in DeletedEnvironments until it is added again or forgotten
+func (c *ProjectConfig) DeleteEnvironment(env string) {
+	if !c.HasEnvironment(env) {
+		return
+	}
+	deleted := c.GetEnvironment(env)
+	c.RemoveEnvironment(env)
+	c.ForgetDeletedEnvironment(env)
+	c.DeletedEnvironments = append(c.DeletedEnvironments, deleted)
+}
+
+// ForgetDeletedEnvironment drops the settings kept for a deleted environment, e.g.
+// once its trash is purged
+func (c *ProjectConfig) ForgetDeletedEnvironment(env string) {
+	c.DeletedEnvironments = slices.DeleteFunc(c.DeletedEnvironments, func(e Environment) bool { return e.Name == env })
+}
+
+// AddEnvironment adds an environment unless it exists already. An environment that
+// was deleted comes back with the settings it was deleted with.
+func (c *ProjectConfig) AddEnvironment(env string) {
+	if !c.HasEnvironment(env) {
+		c.Environments = append(c.Environments, c.GetEnvironment(env))
+		c.ForgetDeletedEnvironment(env)
+	}
 }
 
 // *************** Secrets Config ***************
 
+// Secret is a single key in the secrets file. In yaml a secret can be written as
+// a plain value (SecureString), a list (StringList) or as a map with an explicit type
+// and optional metadata:
+//
+//	KEY1: value1
+//	HOSTS: [a.example.com, b.example.com]
+//	FEATURE_FLAG:
+//	  value: "true"
+//	  type: String
+//	  description: enables the new checkout flow
+//	  owner: payments
+//	  tags:
+//	    team: checkout
+//	  tier: Advanced
+//	  policies:
+//	    expiration: 2025-01-01T00:00:00Z
+//	    expiration_notification: 15 days
+//	    no_change_notification: 30 days
+type Secret struct {
+	Value       string
+	Type        types.ParameterType
+	Description string
+	Owner       string
+	Tags        map[string]string
+	Tier        types.ParameterTier
+	Policies    parameterstore.Policies
+}
+
+// secretPolicies is the yaml representation of the parameter policies of a secret
+type secretPolicies struct {
+	Expiration             string `yaml:"expiration,omitempty"`
+	ExpirationNotification string `yaml:"expiration_notification,omitempty"`
+	NoChangeNotification   string `yaml:"no_change_notification,omitempty"`
+}
+
+// secretValue accepts either a yaml scalar or a yaml list
+type secretValue struct {
+	items  []string
+	isList bool
+}
+
+func (v *secretValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var item string
+	if err := unmarshal(&item); err == nil {
+		v.items = []string{item}
+		return nil
+	}
+
+	if err := unmarshal(&v.items); err != nil {
+		return fmt.Errorf("a secret value must be a string or a list of strings")
+	}
+	v.isList = true
+	return nil
+}
+
+func (s *Secret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value secretValue
+	var typeName string
+
+	if err := unmarshal(&value); err != nil {
+		var fields struct {
+			Value       secretValue       `yaml:"value"`
+			Type        string            `yaml:"type"`
+			Description string            `yaml:"description"`
+			Owner       string            `yaml:"owner"`
+			Tags        map[string]string `yaml:"tags"`
+			Tier        string            `yaml:"tier"`
+			Policies    secretPolicies    `yaml:"policies"`
+		}
+		if err := unmarshal(&fields); err != nil {
+			return err
+		}
+		value = fields.Value
+		typeName = fields.Type
+		s.Description = fields.Description
+		s.Owner = fields.Owner
+		s.Tags = fields.Tags
+
+		tier, err := parseParameterTier(fields.Tier)
+		if err != nil {
+			return err
+		}
+		s.Tier = tier
+
+		policies, err := parameterstore.NewPolicies(fields.Policies.Expiration, fields.Policies.ExpirationNotification, fields.Policies.NoChangeNotification)
+		if err != nil {
+			return err
+		}
+		s.Policies = policies
+	}
+
+	secret, err := newSecret(value, typeName)
+	if err != nil {
+		return err
+	}
+	s.Value = secret.Value
+	s.Type = secret.Type
+	return nil
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	var value interface{} = s.Value
+	if s.EffectiveType() == types.ParameterTypeStringList {
+		value = strings.Split(s.Value, ",")
+	}
+
+	if s.EffectiveType() != types.ParameterTypeString && !s.HasMetadata() {
+		return value, nil
+	}
+
+	fields := yaml.MapSlice{{Key: "value", Value: value}}
+	if s.EffectiveType() == types.ParameterTypeString {
+		fields = append(fields, yaml.MapItem{Key: "type", Value: string(s.Type)})
+	}
+	if s.Description != "" {
+		fields = append(fields, yaml.MapItem{Key: "description", Value: s.Description})
+	}
+	if s.Owner != "" {
+		fields = append(fields, yaml.MapItem{Key: "owner", Value: s.Owner})
+	}
+	if len(s.Tags) > 0 {
+		fields = append(fields, yaml.MapItem{Key: "tags", Value: s.Tags})
+	}
+	if s.Tier != "" {
+		fields = append(fields, yaml.MapItem{Key: "tier", Value: string(s.Tier)})
+	}
+	if !s.Policies.IsEmpty() {
+		fields = append(fields, yaml.MapItem{Key: "policies", Value: secretPolicies{
+			Expiration:             s.Policies.Expiration,
+			ExpirationNotification: s.Policies.ExpirationNotification,
+			NoChangeNotification:   s.Policies.NoChangeNotification,
+		}})
+	}
+	return fields, nil
+}
+
+// HasMetadata reports whether the secret has a description, owner, tags, tier or policies
+func (s Secret) HasMetadata() bool {
+	return s.Description != "" || s.Owner != "" || len(s.Tags) > 0 || s.Tier != "" || !s.Policies.IsEmpty()
+}
+
+// ParameterTags returns the tags to put on the parameter for this secret. The owner
+// and the psenv project and environment are always added as tags.
+func (s Secret) ParameterTags(project, env string) map[string]string {
+	tags := make(map[string]string, len(s.Tags)+3)
+	for k, v := range s.Tags {
+		tags[k] = v
+	}
+	if s.Owner != "" {
+		tags[parameterstore.OwnerTagKey] = s.Owner
+	}
+	tags[parameterstore.ProjectTagKey] = project
+	tags[parameterstore.EnvironmentTagKey] = env
+	return tags
+}
+
+// NewSecretFromParameter creates a secret from a parameter as received from the parameter store.
+// Tags that psenv manages itself are removed so the secret round trips cleanly.
+func NewSecretFromParameter(param parameterstore.Parameter) Secret {
+	secret := Secret{
+		Value:       param.Value,
+		Type:        param.Type,
+		Description: param.Description,
+		Owner:       param.Tags[parameterstore.OwnerTagKey],
+		Policies:    param.Policies,
+	}
+
+	// only keep the tier when put would not pick it automatically. An advanced parameter
+	// can't go back to the standard tier, so advanced is kept even when it is implied.
+	if param.Tier == types.ParameterTierAdvanced || param.Tier != "" && param.Tier != (parameterstore.Parameter{Value: param.Value, Policies: param.Policies}).EffectiveTier() {
+		secret.Tier = param.Tier
+	}
+	for k, v := range param.Tags {
+		switch k {
+		case parameterstore.OwnerTagKey, parameterstore.ProjectTagKey, parameterstore.EnvironmentTagKey:
+			continue
+		}
+		if secret.Tags == nil {
+			secret.Tags = make(map[string]string)
+		}
+		secret.Tags[k] = v
+	}
+	return secret
+}
+
+// EffectiveType returns the parameter type of the secret, defaulting to SecureString
+func (s Secret) EffectiveType() types.ParameterType {
+	if s.Type == "" {
+		return types.ParameterTypeSecureString
+	}
+	return s.Type
+}
+
+func newSecret(value secretValue, typeName string) (Secret, error) {
+	var secret Secret
+
+	paramType, err := parseParameterType(typeName)
+	if err != nil {
+		return secret, err
+	}
+
+	if value.isList {
+		if paramType == "" {
+			paramType = types.ParameterTypeStringList
+		}
+		if paramType != types.ParameterTypeStringList {
+			return secret, fmt.Errorf("a list value can only be used with the %s type", types.ParameterTypeStringList)
+		}
+		for _, item := range value.items {
+			if strings.Contains(item, ",") {
+				return secret, fmt.Errorf("StringList item %q cannot contain a comma", item)
+			}
+		}
+	}
+
+	secret.Value = strings.Join(value.items, ",")
+	secret.Type = paramType
+	return secret, nil
+}
+
+func parseParameterTier(name string) (types.ParameterTier, error) {
+	if name == "" {
+		return "", nil
+	}
+	for _, t := range types.ParameterTierStandard.Values() {
+		if strings.EqualFold(name, string(t)) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("invalid parameter tier %q, must be one of Standard, Advanced or Intelligent-Tiering", name)
+}
+
+func parseParameterType(name string) (types.ParameterType, error) {
+	if name == "" {
+		return "", nil
+	}
+	for _, t := range types.ParameterTypeSecureString.Values() {
+		if strings.EqualFold(name, string(t)) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("invalid parameter type %q, must be one of String, StringList or SecureString", name)
+}
+
 type SecretsConfig struct {
-	Project      string                       `yaml:"project"`
-	Prefix       string                       `yaml:"prefix"`
-	Environments map[string]map[string]string `yaml:"environments"`
+	Project      string             `yaml:"project"`
+	Prefix       string             `yaml:"prefix"`
+	PathTemplate PathTemplate       `yaml:"path_template,omitempty"`
+	PathVars     map[string]string  `yaml:"path_vars,omitempty"`
+	Environments map[string]Secrets `yaml:"environments"`
+}
+
+// Validate checks the parts of the secrets config that can be wrong without failing to parse
+func (c *SecretsConfig) Validate() error {
+	return validatePathVars(c.PathTemplate, c.PathVars)
 }
 
 func (c *SecretsConfig) GetEnvironmentPath(env string) string {
-	return fmt.Sprintf("%s/%s/%s", c.Prefix, c.Project, env)
+	return c.PathTemplate.OrDefault().Expand(pathVars(c.Prefix, c.Project, env, c.PathVars))
+}
+
+// GetTrashPath returns where deleted parameters are kept, see ProjectConfig.GetTrashPath
+func (c *SecretsConfig) GetTrashPath() string {
+	return c.PathTemplate.OrDefault().Expand(pathVars(c.Prefix, c.Project, TrashEnvironment, c.PathVars))
 }
 
-func (c *SecretsConfig) GetEnvironmentParams(env string) map[string]string {
-	keys := make(map[string]string)
+func (c *SecretsConfig) GetEnvironmentParams(env string) map[string]parameterstore.Parameter {
+	keys := make(map[string]parameterstore.Parameter)
 	path := c.GetEnvironmentPath(env)
 	for k, v := range c.Environments[env] {
-		keys[path+"/"+strings.ToUpper(k)] = v
+		name := path + KeySeparator + SecretKey(k)
+		keys[name] = parameterstore.Parameter{
+			Name:        name,
+			Value:       v.Value,
+			Type:        v.EffectiveType(),
+			Description: v.Description,
+			Tags:        v.ParameterTags(c.Project, env),
+			Tier:        v.Tier,
+			Policies:    v.Policies,
+		}
 	}
 	return keys
 }
@@ -96,30 +692,40 @@ func (c *SecretsConfig) ClearEnvironment(env string) {
 }
 
 func (c *SecretsConfig) ClearEnvironments() {
-	c.Environments = make(map[string]map[string]string)
+	c.Environments = make(map[string]Secrets)
 }
 
-// UpdateSecretsConfigFromParameters updates the secrets configuration from the
-// parameters passed in as received from the parameter store
-func (c *SecretsConfig) UpdateSecretsConfigFromParameters(params map[string]string) error {
-	for k, v := range params {
-		parts := strings.Split(k, "/")
+// UpdateSecretsConfigFromParameters updates an environment of the secrets configuration
+// from the parameters read from its path in the parameter store. Parameters nested
+// below the path keep every level of their key, e.g. db/PASSWORD.
+func (c *SecretsConfig) UpdateSecretsConfigFromParameters(env, envPath string, params map[string]parameterstore.Parameter) error {
+	vars, err := c.PathTemplate.OrDefault().Parse(strings.TrimSuffix(envPath, KeySeparator))
+	if err != nil {
+		return err
+	}
+	if vars[PathVarEnv] != env {
+		return fmt.Errorf("path %s is not the path of environment %s", envPath, env)
+	}
 
-		if len(parts) < 3 {
-			return fmt.Errorf("invalid parameter name: %s", k)
+	for k, v := range params {
+		key, ok := RelativeKey(envPath, k)
+		if !ok {
+			return fmt.Errorf("parameter %s is not below %s", k, envPath)
 		}
 
-		env := parts[len(parts)-2]
-		key := strings.ToUpper(parts[len(parts)-1])
-		prefix := strings.Join(parts[0:len(parts)-3], "/")
-		project := parts[len(parts)-3]
-
-		c.Prefix = prefix
-		c.Project = project
 		if _, ok := c.Environments[env]; !ok {
-			c.Environments[env] = make(map[string]string)
+			c.Environments[env] = make(Secrets)
 		}
-		c.Environments[env][key] = v
+		c.Environments[env][SecretKey(key)] = NewSecretFromParameter(v)
+	}
+
+	c.Prefix = vars[PathVarPrefix]
+	c.Project = vars[PathVarProject]
+	delete(vars, PathVarPrefix)
+	delete(vars, PathVarProject)
+	delete(vars, PathVarEnv)
+	if len(vars) > 0 {
+		c.PathVars = vars
 	}
 	return nil
 }
@@ -142,9 +748,14 @@ func (c *SecretsConfig) Save() error {
 
 // LoadProjectConfig loads the project configuration from the project configuration yml file
 func LoadProjectConfig() (*ProjectConfig, error) {
+	return LoadProjectConfigFile(ProjectConfigFile)
+}
+
+// LoadProjectConfigFile loads the project configuration from a yml file at any path
+func LoadProjectConfigFile(path string) (*ProjectConfig, error) {
 	var projectConfig ProjectConfig
 
-	data, err := os.ReadFile(ProjectConfigFile)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -154,6 +765,10 @@ func LoadProjectConfig() (*ProjectConfig, error) {
 		return nil, err
 	}
 
+	if err := projectConfig.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &projectConfig, nil
 }
 
@@ -171,6 +786,10 @@ func LoadSecretsConfig() (*SecretsConfig, error) {
 		return nil, err
 	}
 
+	if err := secretsConfig.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &secretsConfig, nil
 }
 
@@ -178,7 +797,7 @@ func LoadSecretsConfig() (*SecretsConfig, error) {
 func CreateNewProjectConfigFile() (*ProjectConfig, error) {
 	templateData := ProjectConfig{
 		Default:      "dev",
-		Environments: []string{"base", "dev", "prod", "test"},
+		Environments: []Environment{{Name: "base"}, {Name: "dev"}, {Name: "prod"}, {Name: "test"}},
 		Prefix:       "/path/to/params",
 		Project:      "foobar",
 	}
@@ -201,14 +820,14 @@ func CreateNewSecretsConfigFile() (*SecretsConfig, error) {
 	templateData := SecretsConfig{
 		Project: "foobar",
 		Prefix:  "/path/to/params",
-		Environments: map[string]map[string]string{
+		Environments: map[string]Secrets{
 			"dev": {
-				"KEY1": "value1",
-				"KEY2": "value2",
+				"KEY1": {Value: "value1"},
+				"KEY2": {Value: "value2"},
 			},
 			"prod": {
-				"KEY1": "value1",
-				"KEY2": "value2",
+				"KEY1": {Value: "value1"},
+				"KEY2": {Value: "value2"},
 			},
 		},
 	}
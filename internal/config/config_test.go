@@ -3,8 +3,12 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
 )
 
 func createTestFile(t *testing.T, filename, content string) {
@@ -44,7 +48,7 @@ func RemoveTestFiles(t *testing.T, files ...string) {
 func TestProjectConfig_PrintTable(t *testing.T) {
 	projectConfig := &ProjectConfig{
 		Default:      "dev",
-		Environments: []string{"base", "dev", "prod", "test"},
+		Environments: []Environment{{Name: "base"}, {Name: "dev"}, {Name: "prod"}, {Name: "test"}},
 		Prefix:       "/path/to/params",
 		Project:      "foobar",
 	}
@@ -54,7 +58,7 @@ func TestProjectConfig_PrintTable(t *testing.T) {
 func TestProjectConfig_GetEnvironmentPath(t *testing.T) {
 	projectConfig := &ProjectConfig{
 		Default:      "dev",
-		Environments: []string{"base", "dev", "prod", "test"},
+		Environments: []Environment{{Name: "base"}, {Name: "dev"}, {Name: "prod"}, {Name: "test"}},
 		Prefix:       "/path/to/params",
 		Project:      "foobar",
 	}
@@ -62,21 +66,10 @@ func TestProjectConfig_GetEnvironmentPath(t *testing.T) {
 	require.Equal(t, "/path/to/params/foobar/dev", path)
 }
 
-func TestProjectConfig_GetBasePath(t *testing.T) {
-	projectConfig := &ProjectConfig{
-		Default:      "dev",
-		Environments: []string{"base", "dev", "prod", "test"},
-		Prefix:       "/path/to/params",
-		Project:      "foobar",
-	}
-	basePath := projectConfig.GetBasePath()
-	require.Equal(t, "/path/to/params/foobar/", basePath)
-}
-
 func TestProjectConfig_HasEnvironment(t *testing.T) {
 	projectConfig := &ProjectConfig{
 		Default:      "dev",
-		Environments: []string{"base", "dev", "prod", "test"},
+		Environments: []Environment{{Name: "base"}, {Name: "dev"}, {Name: "prod"}, {Name: "test"}},
 		Prefix:       "/path/to/params",
 		Project:      "foobar",
 	}
@@ -84,10 +77,19 @@ func TestProjectConfig_HasEnvironment(t *testing.T) {
 	require.False(t, projectConfig.HasEnvironment("staging"))
 }
 
+func TestProjectConfig_LayeredEnvironments(t *testing.T) {
+	projectConfig := &ProjectConfig{Environments: []Environment{{Name: "base"}, {Name: "dev"}}}
+	require.Equal(t, []string{"base", "dev"}, projectConfig.LayeredEnvironments("dev"))
+	require.Equal(t, []string{"base"}, projectConfig.LayeredEnvironments("base"))
+
+	projectConfig = &ProjectConfig{Environments: []Environment{{Name: "dev"}}}
+	require.Equal(t, []string{"dev"}, projectConfig.LayeredEnvironments("dev"))
+}
+
 func TestProjectConfig_Save(t *testing.T) {
 	projectConfig := &ProjectConfig{
 		Default:      "dev",
-		Environments: []string{"base", "dev", "prod", "test"},
+		Environments: []Environment{{Name: "base"}, {Name: "dev"}, {Name: "prod"}, {Name: "test"}},
 		Prefix:       "/path/to/params",
 		Project:      "foobar",
 	}
@@ -101,22 +103,57 @@ func TestProjectConfig_Save(t *testing.T) {
 func TestProjectConfig_RemoveEnvironment(t *testing.T) {
 	projectConfig := &ProjectConfig{
 		Default:      "dev",
-		Environments: []string{"base", "dev", "prod", "test"},
+		Environments: []Environment{{Name: "base"}, {Name: "dev"}, {Name: "prod"}, {Name: "test"}},
 		Prefix:       "/path/to/params",
 		Project:      "foobar",
 	}
 	projectConfig.RemoveEnvironment("dev")
 	require.False(t, projectConfig.HasEnvironment("dev"))
+
+	projectConfig.AddEnvironment("dev")
+	projectConfig.AddEnvironment("dev")
+	require.Equal(t, []string{"base", "prod", "test", "dev"}, projectConfig.EnvironmentNames())
+}
+
+func TestProjectConfig_DeleteEnvironment(t *testing.T) {
+	prod := Environment{Name: "prod", Profile: "prod", Region: "eu-west-1", KMSKeyID: "alias/prod", Protected: true}
+	projectConfig := &ProjectConfig{
+		Environments: []Environment{{Name: "dev"}, prod},
+		Prefix:       "/path/to/params",
+		Project:      "foobar",
+	}
+
+	// a deleted environment is gone, but its trash is still used with its settings
+	projectConfig.DeleteEnvironment("prod")
+	require.False(t, projectConfig.HasEnvironment("prod"))
+	require.Equal(t, prod, projectConfig.GetEnvironment("prod"))
+	require.Equal(t, "alias/prod", projectConfig.GetKMSKeyID("prod", "alias/aws/ssm"))
+	require.True(t, projectConfig.IsProtected("prod"))
+
+	data, err := yaml.Marshal(projectConfig)
+	require.NoError(t, err)
+	var loaded ProjectConfig
+	require.NoError(t, yaml.Unmarshal(data, &loaded))
+	require.Equal(t, []Environment{prod}, loaded.DeletedEnvironments)
+
+	// adding it again brings its settings back
+	projectConfig.AddEnvironment("prod")
+	require.Equal(t, []Environment{{Name: "dev"}, prod}, projectConfig.Environments)
+	require.Empty(t, projectConfig.DeletedEnvironments)
+
+	projectConfig.DeleteEnvironment("prod")
+	projectConfig.ForgetDeletedEnvironment("prod")
+	require.Equal(t, Environment{Name: "prod"}, projectConfig.GetEnvironment("prod"))
 }
 
 func TestSecretsConfig_GetEnvironmentPath(t *testing.T) {
 	secretsConfig := &SecretsConfig{
 		Project: "foobar",
 		Prefix:  "/path/to/params",
-		Environments: map[string]map[string]string{
+		Environments: map[string]Secrets{
 			"dev": {
-				"KEY1": "value1",
-				"KEY2": "value2",
+				"KEY1": {Value: "value1"},
+				"KEY2": {Value: "value2"},
 			},
 		},
 	}
@@ -128,17 +165,18 @@ func TestSecretsConfig_GetEnvironmentParams(t *testing.T) {
 	secretsConfig := &SecretsConfig{
 		Project: "foobar",
 		Prefix:  "/path/to/params",
-		Environments: map[string]map[string]string{
+		Environments: map[string]Secrets{
 			"dev": {
-				"KEY1": "value1",
-				"KEY2": "value2",
+				"KEY1": {Value: "value1"},
+				"KEY2": {Value: "value2"},
 			},
 		},
 	}
 	params := secretsConfig.GetEnvironmentParams("dev")
-	expected := map[string]string{
-		"/path/to/params/foobar/dev/KEY1": "value1",
-		"/path/to/params/foobar/dev/KEY2": "value2",
+	tags := map[string]string{parameterstore.ProjectTagKey: "foobar", parameterstore.EnvironmentTagKey: "dev"}
+	expected := map[string]parameterstore.Parameter{
+		"/path/to/params/foobar/dev/KEY1": {Name: "/path/to/params/foobar/dev/KEY1", Value: "value1", Type: types.ParameterTypeSecureString, Tags: tags},
+		"/path/to/params/foobar/dev/KEY2": {Name: "/path/to/params/foobar/dev/KEY2", Value: "value2", Type: types.ParameterTypeSecureString, Tags: tags},
 	}
 	require.Equal(t, expected, params)
 }
@@ -147,10 +185,10 @@ func TestSecretsConfig_ClearEnvironment(t *testing.T) {
 	secretsConfig := &SecretsConfig{
 		Project: "foobar",
 		Prefix:  "/path/to/params",
-		Environments: map[string]map[string]string{
+		Environments: map[string]Secrets{
 			"dev": {
-				"KEY1": "value1",
-				"KEY2": "value2",
+				"KEY1": {Value: "value1"},
+				"KEY2": {Value: "value2"},
 			},
 		},
 	}
@@ -162,10 +200,10 @@ func TestSecretsConfig_ClearEnvironments(t *testing.T) {
 	secretsConfig := &SecretsConfig{
 		Project: "foobar",
 		Prefix:  "/path/to/params",
-		Environments: map[string]map[string]string{
+		Environments: map[string]Secrets{
 			"dev": {
-				"KEY1": "value1",
-				"KEY2": "value2",
+				"KEY1": {Value: "value1"},
+				"KEY2": {Value: "value2"},
 			},
 		},
 	}
@@ -175,26 +213,26 @@ func TestSecretsConfig_ClearEnvironments(t *testing.T) {
 
 func TestSecretsConfig_UpdateSecretsConfigFromParameters(t *testing.T) {
 	secretsConfig := &SecretsConfig{
-		Environments: make(map[string]map[string]string),
+		Environments: make(map[string]Secrets),
 	}
-	params := map[string]string{
-		"/path/to/params/foobar/dev/KEY1": "value1",
-		"/path/to/params/foobar/dev/KEY2": "value2",
+	params := map[string]parameterstore.Parameter{
+		"/path/to/params/foobar/dev/KEY1": {Value: "value1", Type: types.ParameterTypeSecureString},
+		"/path/to/params/foobar/dev/KEY2": {Value: "a,b", Type: types.ParameterTypeStringList},
 	}
-	err := secretsConfig.UpdateSecretsConfigFromParameters(params)
+	err := secretsConfig.UpdateSecretsConfigFromParameters("dev", "/path/to/params/foobar/dev", params)
 	require.NoError(t, err)
-	require.Equal(t, "value1", secretsConfig.Environments["dev"]["KEY1"])
-	require.Equal(t, "value2", secretsConfig.Environments["dev"]["KEY2"])
+	require.Equal(t, "value1", secretsConfig.Environments["dev"]["KEY1"].Value)
+	require.Equal(t, Secret{Value: "a,b", Type: types.ParameterTypeStringList}, secretsConfig.Environments["dev"]["KEY2"])
 }
 
 func TestSecretsConfig_Save(t *testing.T) {
 	secretsConfig := &SecretsConfig{
 		Project: "foobar",
 		Prefix:  "/path/to/params",
-		Environments: map[string]map[string]string{
+		Environments: map[string]Secrets{
 			"dev": {
-				"KEY1": "value1",
-				"KEY2": "value2",
+				"KEY1": {Value: "value1"},
+				"KEY2": {Value: "value2"},
 			},
 		},
 	}
@@ -235,3 +273,451 @@ func TestCreateNewSecretsConfigFile(t *testing.T) {
 	require.NoError(t, err)
 	RemoveTestFiles(t, SecretsConfigFile)
 }
+
+func TestSecret_UnmarshalYAML(t *testing.T) {
+	var secrets map[string]Secret
+	err := yaml.Unmarshal([]byte(`
+KEY1: value1
+HOSTS:
+  - a.example.com
+  - b.example.com
+FLAG:
+  value: "true"
+  type: String
+PORTS:
+  value: [80, 443]
+  type: StringList
+`), &secrets)
+	require.NoError(t, err)
+	require.Equal(t, Secret{Value: "value1"}, secrets["KEY1"])
+	require.Equal(t, Secret{Value: "a.example.com,b.example.com", Type: types.ParameterTypeStringList}, secrets["HOSTS"])
+	require.Equal(t, Secret{Value: "true", Type: types.ParameterTypeString}, secrets["FLAG"])
+	require.Equal(t, Secret{Value: "80,443", Type: types.ParameterTypeStringList}, secrets["PORTS"])
+}
+
+func TestSecret_UnmarshalYAMLRejectsInvalidValues(t *testing.T) {
+	var secrets map[string]Secret
+	require.Error(t, yaml.Unmarshal([]byte("KEY1: {value: x, type: Number}"), &secrets))
+	require.Error(t, yaml.Unmarshal([]byte("KEY1: {value: [a, b], type: String}"), &secrets))
+	require.Error(t, yaml.Unmarshal([]byte("KEY1: [\"a,b\", c]"), &secrets))
+}
+
+func TestSecret_MarshalYAMLRoundTrip(t *testing.T) {
+	secrets := map[string]Secret{
+		"FLAG":  {Value: "true", Type: types.ParameterTypeString},
+		"HOSTS": {Value: "a,b", Type: types.ParameterTypeStringList},
+		"KEY1":  {Value: "value1", Type: types.ParameterTypeSecureString},
+	}
+	data, err := yaml.Marshal(secrets)
+	require.NoError(t, err)
+	require.Equal(t, "FLAG:\n  value: \"true\"\n  type: String\nHOSTS:\n- a\n- b\nKEY1: value1\n", string(data))
+
+	var loaded map[string]Secret
+	require.NoError(t, yaml.Unmarshal(data, &loaded))
+	require.Equal(t, types.ParameterTypeString, loaded["FLAG"].Type)
+	require.Equal(t, "a,b", loaded["HOSTS"].Value)
+	require.Equal(t, types.ParameterTypeSecureString, loaded["KEY1"].EffectiveType())
+}
+
+func TestSecret_MetadataRoundTrip(t *testing.T) {
+	var secrets map[string]Secret
+	err := yaml.Unmarshal([]byte(`
+DB_PASSWORD:
+  value: hunter2
+  description: the database password
+  owner: payments
+  tags:
+    team: checkout
+`), &secrets)
+	require.NoError(t, err)
+
+	secret := secrets["DB_PASSWORD"]
+	require.Equal(t, "the database password", secret.Description)
+	require.Equal(t, map[string]string{
+		"team":                           "checkout",
+		parameterstore.OwnerTagKey:       "payments",
+		parameterstore.ProjectTagKey:     "foobar",
+		parameterstore.EnvironmentTagKey: "dev",
+	}, secret.ParameterTags("foobar", "dev"))
+
+	param := parameterstore.Parameter{
+		Value:       "hunter2",
+		Type:        types.ParameterTypeSecureString,
+		Description: secret.Description,
+		Tags:        secret.ParameterTags("foobar", "dev"),
+	}
+	roundTripped := NewSecretFromParameter(param)
+	require.Equal(t, secret.Owner, roundTripped.Owner)
+	require.Equal(t, secret.Tags, roundTripped.Tags)
+
+	data, err := yaml.Marshal(roundTripped)
+	require.NoError(t, err)
+	require.Equal(t, "value: hunter2\ndescription: the database password\nowner: payments\ntags:\n  team: checkout\n", string(data))
+}
+
+func TestSecret_TierAndPolicies(t *testing.T) {
+	var secrets map[string]Secret
+	err := yaml.Unmarshal([]byte(`
+API_TOKEN:
+  value: token
+  tier: advanced
+  policies:
+    expiration: 2025-01-01T00:00:00Z
+    expiration_notification: 15 days
+`), &secrets)
+	require.NoError(t, err)
+
+	secret := secrets["API_TOKEN"]
+	require.Equal(t, types.ParameterTierAdvanced, secret.Tier)
+	require.Equal(t, "2025-01-01T00:00:00.000Z", secret.Policies.Expiration)
+	require.Equal(t, "15 days", secret.Policies.ExpirationNotification)
+
+	data, err := yaml.Marshal(secret)
+	require.NoError(t, err)
+	require.Equal(t, "value: token\ntier: Advanced\npolicies:\n  expiration: \"2025-01-01T00:00:00.000Z\"\n  expiration_notification: 15 days\n", string(data))
+
+	require.Error(t, yaml.Unmarshal([]byte("KEY: {value: x, tier: Premium}"), &secrets))
+	require.Error(t, yaml.Unmarshal([]byte("KEY: {value: x, policies: {expiration: never}}"), &secrets))
+}
+
+func TestNewSecretFromParameterDropsImpliedTier(t *testing.T) {
+	secret := NewSecretFromParameter(parameterstore.Parameter{Value: "small", Tier: types.ParameterTierStandard})
+	require.Empty(t, secret.Tier)
+
+	secret = NewSecretFromParameter(parameterstore.Parameter{Value: "small", Tier: types.ParameterTierAdvanced})
+	require.Equal(t, types.ParameterTierAdvanced, secret.Tier)
+
+	// advanced is kept even when implied, removing the policies can't make it standard again
+	secret = NewSecretFromParameter(parameterstore.Parameter{
+		Value:    "small",
+		Tier:     types.ParameterTierAdvanced,
+		Policies: parameterstore.Policies{Expiration: "2030-01-01T00:00:00.000Z"},
+	})
+	require.Equal(t, types.ParameterTierAdvanced, secret.Tier)
+}
+
+func TestProjectConfig_EnvironmentEntries(t *testing.T) {
+	var projectConfig ProjectConfig
+	err := yaml.Unmarshal([]byte(`
+environments:
+  - dev
+  - name: prod
+    profile: prod-admin
+    region: eu-west-1
+    role_arn: arn:aws:iam::123456789012:role/psenv
+    mfa_serial: arn:aws:iam::123456789012:mfa/jesse
+    kms_key_id: alias/prod-secrets
+prefix: /path/to/params
+project: foobar
+`), &projectConfig)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev", "prod"}, projectConfig.EnvironmentNames())
+	require.Equal(t, "eu-west-1", projectConfig.GetEnvironment("prod").Region)
+	require.Len(t, projectConfig.GetClientOptions("prod"), 4)
+	require.Empty(t, projectConfig.GetClientOptions("dev"))
+	require.Equal(t, "alias/prod-secrets", projectConfig.GetKMSKeyID("prod", "alias/aws/ssm"))
+	require.Equal(t, "alias/aws/ssm", projectConfig.GetKMSKeyID("dev", "alias/aws/ssm"))
+
+	data, err := yaml.Marshal(projectConfig.Environments)
+	require.NoError(t, err)
+	require.Equal(t, "- dev\n- name: prod\n  profile: prod-admin\n  region: eu-west-1\n  role_arn: arn:aws:iam::123456789012:role/psenv\n  mfa_serial: arn:aws:iam::123456789012:mfa/jesse\n  kms_key_id: alias/prod-secrets\n", string(data))
+
+	require.Error(t, yaml.Unmarshal([]byte("environments: [{region: eu-west-1}]"), &projectConfig))
+}
+
+func TestProjectConfig_EndpointClientOptions(t *testing.T) {
+	projectConfig := &ProjectConfig{
+		Environments: []Environment{{Name: "dev", Profile: "dev"}},
+		Endpoint:     &Endpoint{URL: "http://localhost:4566", AccessKeyID: "test", SecretAccessKey: "test"},
+	}
+	require.Len(t, projectConfig.GetClientOptions("dev"), 3)
+
+	t.Setenv(EndpointURLEnvVar, "http://localhost:8080")
+	t.Setenv(RegionEnvVar, "eu-west-1")
+	endpoint := EndpointFromEnv()
+	require.Equal(t, Endpoint{URL: "http://localhost:8080", Region: "eu-west-1"}, endpoint)
+	require.Len(t, endpoint.ClientOptions(), 2)
+
+	var noEndpoint *Endpoint
+	require.Empty(t, noEndpoint.ClientOptions())
+}
+
+func TestProjectConfig_Retry(t *testing.T) {
+	var projectConfig ProjectConfig
+	err := yaml.Unmarshal([]byte(`
+environments: [dev]
+retry:
+  mode: standard
+  max_attempts: 10
+  max_backoff: 30s
+`), &projectConfig)
+	require.NoError(t, err)
+	require.Equal(t, &Retry{Mode: "standard", MaxAttempts: 10, MaxBackoff: 30 * time.Second}, projectConfig.Retry)
+	require.Len(t, projectConfig.GetClientOptions("dev"), 3)
+
+	data, err := yaml.Marshal(projectConfig.Retry)
+	require.NoError(t, err)
+	require.Equal(t, "mode: standard\nmax_attempts: 10\nmax_backoff: 30s\n", string(data))
+
+	require.Error(t, yaml.Unmarshal([]byte("retry: {mode: sometimes}"), &projectConfig))
+	require.Error(t, yaml.Unmarshal([]byte("retry: {max_backoff: forever}"), &projectConfig))
+}
+
+func TestNaming_EnvVarName(t *testing.T) {
+	var noNaming *Naming
+	require.Equal(t, "db_host", noNaming.EnvVarName("db_host"))
+
+	naming := &Naming{
+		AddPrefix:   "APP_",
+		StripPrefix: "legacy_",
+		Case:        NamingCaseUpper,
+		Rename:      map[string]string{"DB_PASS": "DATABASE_PASSWORD"},
+	}
+	require.Equal(t, "APP_DB_HOST", naming.EnvVarName("legacy_db_host"))
+	require.Equal(t, "APP_PORT", naming.EnvVarName("port"))
+	require.Equal(t, "DATABASE_PASSWORD", naming.EnvVarName("DB_PASS"))
+
+	require.Equal(t, "db_host", (&Naming{Case: NamingCaseLower}).EnvVarName("DB_HOST"))
+}
+
+func TestNaming_EnvVars(t *testing.T) {
+	base := map[string]parameterstore.Parameter{
+		"/p/proj/base/HOST": {Name: "/p/proj/base/HOST", Value: "base-host"},
+		"/p/proj/base/PORT": {Name: "/p/proj/base/PORT", Value: "5432"},
+	}
+	dev := map[string]parameterstore.Parameter{
+		"/p/proj/dev/HOST": {Name: "/p/proj/dev/HOST", Value: "dev-host"},
+	}
+
+	values, sources, err := (&Naming{AddPrefix: "APP_"}).EnvVars(Layer{"/p/proj/base", base}, Layer{"/p/proj/dev", dev})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"APP_HOST": "dev-host", "APP_PORT": "5432"}, values)
+	require.Equal(t, "/p/proj/dev/HOST", sources["APP_HOST"])
+	require.Equal(t, "/p/proj/base/PORT", sources["APP_PORT"])
+
+	dev["/p/proj/dev/host"] = parameterstore.Parameter{Name: "/p/proj/dev/host", Value: "lower"}
+	_, _, err = (&Naming{Case: NamingCaseUpper}).EnvVars(Layer{"/p/proj/base", base}, Layer{"/p/proj/dev", dev})
+	require.ErrorContains(t, err, "/p/proj/dev/HOST and /p/proj/dev/host both map to HOST")
+}
+
+func TestProjectConfig_Naming(t *testing.T) {
+	var projectConfig ProjectConfig
+	err := yaml.Unmarshal([]byte(`
+environments: [dev]
+naming:
+  add_prefix: APP_
+  strip_prefix: LEGACY_
+  case: upper
+  rename:
+    DB_PASS: DATABASE_PASSWORD
+`), &projectConfig)
+	require.NoError(t, err)
+	require.Equal(t, &Naming{
+		AddPrefix:   "APP_",
+		StripPrefix: "LEGACY_",
+		Case:        NamingCaseUpper,
+		Rename:      map[string]string{"DB_PASS": "DATABASE_PASSWORD"},
+	}, projectConfig.Naming)
+	require.NoError(t, projectConfig.Validate())
+
+	require.Error(t, (&Naming{Case: "title"}).Validate())
+	require.Error(t, (&Naming{Rename: map[string]string{"KEY": "NOT-VALID"}}).Validate())
+}
+
+func TestNaming_Flatten(t *testing.T) {
+	var noNaming *Naming
+	require.Equal(t, "DB_PASSWORD", noNaming.EnvVarName("db/PASSWORD"))
+	require.Equal(t, "APP_DB_PASSWORD", (&Naming{AddPrefix: "APP_"}).EnvVarName("db/PASSWORD"))
+	require.Equal(t, "db_PASSWORD", (&Naming{Case: NamingCasePreserve}).EnvVarName("db/PASSWORD"))
+
+	require.Equal(t, "DB_PASSWORD", (&Naming{Case: NamingCaseUpper}).EnvVarName("db/PASSWORD"))
+	require.Equal(t, "DB__PRIMARY__PASSWORD", (&Naming{Case: NamingCaseUpper, Separator: "__"}).EnvVarName("db/primary/PASSWORD"))
+	require.Equal(t, "PASSWORD", (&Naming{Flatten: NamingFlattenLeaf}).EnvVarName("db/PASSWORD"))
+	require.Equal(t, "DB_PASS", (&Naming{Rename: map[string]string{"db/PASSWORD": "DB_PASS"}}).EnvVarName("db/PASSWORD"))
+
+	require.Error(t, (&Naming{Flatten: "squash"}).Validate())
+
+	dev := map[string]parameterstore.Parameter{
+		"/p/proj/dev/db/PASSWORD":    {Value: "db"},
+		"/p/proj/dev/cache/PASSWORD": {Value: "cache"},
+	}
+	values, sources, err := (&Naming{Case: NamingCaseUpper}).EnvVars(Layer{"/p/proj/dev", dev})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"DB_PASSWORD": "db", "CACHE_PASSWORD": "cache"}, values)
+	require.Equal(t, "/p/proj/dev/db/PASSWORD", sources["DB_PASSWORD"])
+
+	_, _, err = (&Naming{Flatten: NamingFlattenLeaf}).EnvVars(Layer{"/p/proj/dev", dev})
+	require.ErrorContains(t, err, "both map to PASSWORD")
+
+	// a flat key and a nested key can end up with the same name
+	dev["/p/proj/dev/DB_PASSWORD"] = parameterstore.Parameter{Value: "flat"}
+	_, _, err = noNaming.EnvVars(Layer{"/p/proj/dev", dev})
+	require.ErrorContains(t, err, "/p/proj/dev/DB_PASSWORD and /p/proj/dev/db/PASSWORD both map to DB_PASSWORD")
+}
+
+func TestNaming_EnvVarsRejectsInvalidNames(t *testing.T) {
+	var noNaming *Naming
+	dev := map[string]parameterstore.Parameter{
+		"/p/proj/dev/api-gateway/URL": {Value: "url"},
+		"/p/proj/dev/2FA_SECRET":      {Value: "secret"},
+		"/p/proj/dev/HOST":            {Value: "host"},
+	}
+	_, _, err := noNaming.EnvVars(Layer{"/p/proj/dev", dev})
+	require.ErrorContains(t, err, "API-GATEWAY_URL from /p/proj/dev/api-gateway/URL")
+	require.ErrorContains(t, err, "2FA_SECRET from /p/proj/dev/2FA_SECRET")
+
+	naming := &Naming{Rename: map[string]string{"api-gateway/URL": "API_GATEWAY_URL", "2FA_SECRET": "TWO_FA_SECRET"}}
+	values, _, err := naming.EnvVars(Layer{"/p/proj/dev", dev})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"API_GATEWAY_URL": "url", "TWO_FA_SECRET": "secret", "HOST": "host"}, values)
+}
+
+func TestSecrets_NestedYAML(t *testing.T) {
+	var secretsConfig SecretsConfig
+	err := yaml.Unmarshal([]byte(`
+project: foobar
+prefix: /path/to/params
+environments:
+  dev:
+    HOST: localhost
+    db:
+      PASSWORD:
+        value: secret
+        type: SecureString
+      primary:
+        port: "5432"
+`), &secretsConfig)
+	require.NoError(t, err)
+	require.Equal(t, Secrets{
+		"HOST":            {Value: "localhost"},
+		"db/PASSWORD":     {Value: "secret", Type: types.ParameterTypeSecureString},
+		"db/primary/port": {Value: "5432"},
+	}, secretsConfig.Environments["dev"])
+
+	params := secretsConfig.GetEnvironmentParams("dev")
+	require.Contains(t, params, "/path/to/params/foobar/dev/db/PASSWORD")
+	require.Contains(t, params, "/path/to/params/foobar/dev/db/primary/PORT")
+
+	data, err := yaml.Marshal(secretsConfig.Environments["dev"])
+	require.NoError(t, err)
+	require.Equal(t, "HOST: localhost\ndb:\n  PASSWORD: secret\n  primary:\n    port: \"5432\"\n", string(data))
+
+	require.Error(t, yaml.Unmarshal([]byte("{db/KEY: a, db: {KEY: b}}"), &Secrets{}))
+	_, err = yaml.Marshal(Secrets{"db": {Value: "a"}, "db/KEY": {Value: "b"}})
+	require.Error(t, err)
+}
+
+func TestSecretsConfig_UpdateNestedParameters(t *testing.T) {
+	secretsConfig := &SecretsConfig{Environments: make(map[string]Secrets)}
+	params := map[string]parameterstore.Parameter{
+		"/path/to/params/foobar/dev/KEY1":        {Value: "value1"},
+		"/path/to/params/foobar/dev/db/PASSWORD": {Value: "secret", Type: types.ParameterTypeSecureString},
+	}
+	err := secretsConfig.UpdateSecretsConfigFromParameters("dev", "/path/to/params/foobar/dev", params)
+	require.NoError(t, err)
+	require.Equal(t, "/path/to/params", secretsConfig.Prefix)
+	require.Equal(t, "foobar", secretsConfig.Project)
+	require.Equal(t, "secret", secretsConfig.Environments["dev"]["db/PASSWORD"].Value)
+
+	err = secretsConfig.UpdateSecretsConfigFromParameters("dev", "/path/to/params/foobar/dev", map[string]parameterstore.Parameter{
+		"/path/to/params/foobar/prod/KEY1": {Value: "value1"},
+	})
+	require.Error(t, err)
+}
+
+func TestPathTemplate(t *testing.T) {
+	template := PathTemplate("/{env}/{team}/{project}")
+	vars := map[string]string{"team": "platform"}
+	require.NoError(t, template.Validate(vars))
+	require.Equal(t, "/dev/platform/foobar", template.Expand(pathVars("", "foobar", "dev", vars)))
+
+	parsed, err := template.Parse("/dev/platform/foobar")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"env": "dev", "team": "platform", "project": "foobar"}, parsed)
+
+	_, err = template.Parse("/dev/platform/foobar/extra")
+	require.Error(t, err)
+
+	parsed, err = DefaultPathTemplate.Parse("/path/to/params/foobar/dev")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"prefix": "/path/to/params", "project": "foobar", "env": "dev"}, parsed)
+
+	require.ErrorContains(t, PathTemplate("/{project}").Validate(nil), "must contain {env}")
+	require.ErrorContains(t, PathTemplate("/{env}/{team}").Validate(nil), "unknown placeholder {team}")
+	require.ErrorContains(t, PathTemplate("/{env}/{env}").Validate(nil), "more than once")
+	require.ErrorContains(t, PathTemplate("/{env}//{project}").Validate(nil), "empty level")
+	require.ErrorContains(t, PathTemplate("{project}/{env}").Validate(nil), "must start with /")
+	require.ErrorContains(t, PathTemplate("apps/{env}").Validate(nil), "must start with /")
+	require.NoError(t, PathTemplate("{prefix}/{env}").Validate(nil))
+	require.Error(t, validatePathVars(template, map[string]string{"team": "a/b"}))
+	require.Error(t, validatePathVars(template, map[string]string{"team": "platform", "env": "dev"}))
+}
+
+func TestProjectConfig_PathTemplate(t *testing.T) {
+	var projectConfig ProjectConfig
+	err := yaml.Unmarshal([]byte(`
+environments: [dev]
+prefix: /ignored
+project: foobar
+path_template: /{env}/{team}/{project}
+path_vars:
+  team: platform
+`), &projectConfig)
+	require.NoError(t, err)
+	require.NoError(t, projectConfig.Validate())
+	require.Equal(t, "/dev/platform/foobar", projectConfig.GetEnvironmentPath("dev"))
+
+	projectConfig.PathVars = nil
+	require.ErrorContains(t, projectConfig.Validate(), "unknown placeholder {team}")
+}
+
+func TestSecretsConfig_PathTemplate(t *testing.T) {
+	secretsConfig := &SecretsConfig{
+		PathTemplate: "/{env}/{team}/{project}",
+		Environments: make(map[string]Secrets),
+	}
+	params := map[string]parameterstore.Parameter{
+		"/dev/platform/foobar/KEY1":        {Value: "value1"},
+		"/dev/platform/foobar/db/PASSWORD": {Value: "secret"},
+	}
+	err := secretsConfig.UpdateSecretsConfigFromParameters("dev", "/dev/platform/foobar", params)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", secretsConfig.Project)
+	require.Equal(t, map[string]string{"team": "platform"}, secretsConfig.PathVars)
+	require.Equal(t, "secret", secretsConfig.Environments["dev"]["db/PASSWORD"].Value)
+
+	require.Contains(t, secretsConfig.GetEnvironmentParams("dev"), "/dev/platform/foobar/db/PASSWORD")
+	require.Equal(t, "/prod/platform/foobar", secretsConfig.GetEnvironmentPath("prod"))
+
+	err = secretsConfig.UpdateSecretsConfigFromParameters("prod", "/dev/platform/foobar", nil)
+	require.ErrorContains(t, err, "not the path of environment prod")
+}
+
+func TestProjectConfig_Protected(t *testing.T) {
+	var projectConfig ProjectConfig
+	err := yaml.Unmarshal([]byte(`
+environments:
+  - dev
+  - name: prod
+    protected: true
+`), &projectConfig)
+	require.NoError(t, err)
+	require.True(t, projectConfig.IsProtected("prod"))
+	require.False(t, projectConfig.IsProtected("dev"))
+	require.False(t, projectConfig.IsProtected("missing"))
+
+	data, err := yaml.Marshal(projectConfig.Environments)
+	require.NoError(t, err)
+	require.Equal(t, "- dev\n- name: prod\n  protected: true\n", string(data))
+}
+
+func TestProjectConfig_TrashPath(t *testing.T) {
+	projectConfig := &ProjectConfig{Prefix: "/psenv", Project: "foobar"}
+	require.Equal(t, "/psenv/foobar/.trash", projectConfig.GetTrashPath())
+
+	projectConfig.PathTemplate = "/{env}/{project}"
+	require.Equal(t, "/.trash/foobar", projectConfig.GetTrashPath())
+
+	projectConfig.Environments = []Environment{{Name: TrashEnvironment}}
+	require.ErrorContains(t, projectConfig.Validate(), ".trash can't be used as an environment name")
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// KeySeparator separates the levels of a key nested below the environment path,
+// e.g. db/PASSWORD for /prefix/project/dev/db/PASSWORD
+const KeySeparator = "/"
+
+// Secrets holds the secrets of one environment by key. Keys of parameters nested below
+// the environment path hold every level, e.g. db/PASSWORD. In psenv-secrets.yml the
+// levels are nested maps:
+//
+//	dev:
+//	  HOST: localhost
+//	  db:
+//	    PASSWORD: secret
+//
+// A map with a value field is a secret, any other map is a level of the hierarchy.
+type Secrets map[string]Secret
+
+// secretNode is either a secret or a level of nested secrets
+type secretNode struct {
+	secret   *Secret
+	children map[string]secretNode
+}
+
+func (n *secretNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var fields map[string]interface{}
+	if err := unmarshal(&fields); err == nil {
+		if _, ok := fields["value"]; !ok {
+			return unmarshal(&n.children)
+		}
+	}
+
+	n.secret = &Secret{}
+	return unmarshal(n.secret)
+}
+
+func (s *Secrets) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var nodes map[string]secretNode
+	if err := unmarshal(&nodes); err != nil {
+		return err
+	}
+
+	*s = make(Secrets)
+	return s.flatten("", nodes)
+}
+
+// flatten adds the secrets of nested nodes with the keys of every level joined
+func (s Secrets) flatten(parent string, nodes map[string]secretNode) error {
+	for name, node := range nodes {
+		key := strings.Trim(name, KeySeparator)
+		if parent != "" {
+			key = parent + KeySeparator + key
+		}
+
+		if node.secret == nil {
+			if err := s.flatten(key, node.children); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, ok := s[key]; ok {
+			return fmt.Errorf("secret %s is defined more than once", key)
+		}
+		s[key] = *node.secret
+	}
+	return nil
+}
+
+func (s Secrets) MarshalYAML() (interface{}, error) {
+	return s.nest()
+}
+
+// nest returns the secrets as nested maps, one for every level of their keys
+func (s Secrets) nest() (yaml.MapSlice, error) {
+	secrets := make(map[string]Secret)
+	levels := make(map[string]Secrets)
+	for key, secret := range s {
+		level, rest, nested := strings.Cut(key, KeySeparator)
+		if !nested {
+			secrets[key] = secret
+			continue
+		}
+		if _, ok := levels[level]; !ok {
+			levels[level] = make(Secrets)
+		}
+		levels[level][rest] = secret
+	}
+
+	names := make([]string, 0, len(secrets)+len(levels))
+	for name := range secrets {
+		if _, ok := levels[name]; ok {
+			return nil, fmt.Errorf("%s is both a secret and a level of nested secrets", name)
+		}
+		names = append(names, name)
+	}
+	for name := range levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make(yaml.MapSlice, 0, len(names))
+	for _, name := range names {
+		if secret, ok := secrets[name]; ok {
+			items = append(items, yaml.MapItem{Key: name, Value: secret})
+			continue
+		}
+
+		nested, err := levels[name].nest()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, yaml.MapItem{Key: name, Value: nested})
+	}
+	return items, nil
+}
+
+// RelativeKey returns the key of a parameter below an environment path, e.g.
+// db/PASSWORD for /prefix/project/dev/db/PASSWORD below /prefix/project/dev
+func RelativeKey(envPath, name string) (string, bool) {
+	key, ok := strings.CutPrefix(name, strings.TrimSuffix(envPath, KeySeparator)+KeySeparator)
+	if !ok || key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+// SecretKey returns how a key is named in the parameter store. Only the last level is
+// upper cased, the levels above it are kept as they are.
+func SecretKey(key string) string {
+	levels := strings.Split(strings.Trim(key, KeySeparator), KeySeparator)
+	levels[len(levels)-1] = strings.ToUpper(levels[len(levels)-1])
+	return strings.Join(levels, KeySeparator)
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+)
+
+// case policies of Naming
+const (
+	NamingCasePreserve = "preserve"
+	NamingCaseUpper    = "upper"
+	NamingCaseLower    = "lower"
+)
+
+// flattening strategies of Naming for keys nested below the environment path
+const (
+	// NamingFlattenJoin joins every level of the key, db/PASSWORD becomes DB_PASSWORD
+	NamingFlattenJoin = "join"
+
+	// NamingFlattenLeaf keeps only the last level, db/PASSWORD becomes PASSWORD
+	NamingFlattenLeaf = "leaf"
+)
+
+// DefaultNamingSeparator joins the levels of nested keys
+const DefaultNamingSeparator = "_"
+
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Naming configures how parameter keys become environment variable names:
+//
+//	naming:
+//	  strip_prefix: LEGACY_
+//	  add_prefix: APP_
+//	  case: upper
+//	  flatten: join
+//	  separator: _
+//	  rename:
+//	    DB_PASS: DATABASE_PASSWORD
+//
+// A key in rename is used exactly as the name given, otherwise a nested key is
+// flattened, the prefix stripped, the case policy applied and then the prefix added.
+// Without a case policy flattened keys are upper cased, so db/PASSWORD becomes
+// DB_PASSWORD, and other keys are kept as they are. Case preserve keeps db_PASSWORD.
+type Naming struct {
+	AddPrefix   string            `yaml:"add_prefix,omitempty"`
+	StripPrefix string            `yaml:"strip_prefix,omitempty"`
+	Case        string            `yaml:"case,omitempty"`
+	Flatten     string            `yaml:"flatten,omitempty"`
+	Separator   string            `yaml:"separator,omitempty"`
+	Rename      map[string]string `yaml:"rename,omitempty"`
+}
+
+// Layer is the parameters read from one environment path
+type Layer struct {
+	Path   string
+	Params map[string]parameterstore.Parameter
+}
+
+// Validate returns an error for an unknown case policy or flattening strategy, or a
+// rename to an invalid name
+func (n *Naming) Validate() error {
+	if n == nil {
+		return nil
+	}
+
+	switch n.Case {
+	case "", NamingCasePreserve, NamingCaseUpper, NamingCaseLower:
+	default:
+		return fmt.Errorf("invalid naming case %q, must be %s, %s or %s", n.Case, NamingCasePreserve, NamingCaseUpper, NamingCaseLower)
+	}
+
+	switch n.Flatten {
+	case "", NamingFlattenJoin, NamingFlattenLeaf:
+	default:
+		return fmt.Errorf("invalid naming flatten %q, must be %s or %s", n.Flatten, NamingFlattenJoin, NamingFlattenLeaf)
+	}
+
+	for key, name := range n.Rename {
+		if !envVarNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q for key %s in naming.rename", name, key)
+		}
+	}
+	return nil
+}
+
+// EnvVarName returns the environment variable name of a parameter key, which is
+// relative to the environment path and may be nested, e.g. db/PASSWORD. The name is
+// not checked, see EnvVars.
+func (n *Naming) EnvVarName(key string) string {
+	nested := strings.Contains(key, KeySeparator)
+	if n == nil {
+		if nested {
+			return strings.ToUpper(n.flatten(key))
+		}
+		return key
+	}
+
+	if name, ok := n.Rename[key]; ok {
+		return name
+	}
+
+	name := strings.TrimPrefix(n.flatten(key), n.StripPrefix)
+	switch {
+	case n.Case == NamingCaseUpper, n.Case == "" && nested:
+		name = strings.ToUpper(name)
+	case n.Case == NamingCaseLower:
+		name = strings.ToLower(name)
+	}
+	return n.AddPrefix + name
+}
+
+// flatten turns a nested key into a single name
+func (n *Naming) flatten(key string) string {
+	levels := strings.Split(key, KeySeparator)
+	if n != nil && n.Flatten == NamingFlattenLeaf {
+		return levels[len(levels)-1]
+	}
+
+	separator := DefaultNamingSeparator
+	if n != nil && n.Separator != "" {
+		separator = n.Separator
+	}
+	return strings.Join(levels, separator)
+}
+
+// EnvVars converts layers of parameters to environment variables. Every layer is
+// usually the parameters of one environment, and later layers override earlier ones,
+// so base comes first. It returns the values and the parameter each value came from,
+// both by environment variable name. Two parameters of the same layer that end up
+// with the same name are an error, and so is a name that is not a valid variable name.
+func (n *Naming) EnvVars(layers ...Layer) (map[string]string, map[string]string, error) {
+	values := make(map[string]string)
+	sources := make(map[string]string)
+
+	var collisions, invalid []string
+	for _, layer := range layers {
+		names := make([]string, 0, len(layer.Params))
+		for name := range layer.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		layerSources := make(map[string]string)
+		for _, name := range names {
+			key, ok := RelativeKey(layer.Path, name)
+			if !ok {
+				key = path.Base(name)
+			}
+
+			envVar := n.EnvVarName(key)
+			if !envVarNamePattern.MatchString(envVar) {
+				invalid = append(invalid, fmt.Sprintf("%s from %s", envVar, name))
+				continue
+			}
+			if other, ok := layerSources[envVar]; ok {
+				collisions = append(collisions, fmt.Sprintf("%s and %s both map to %s", other, name, envVar))
+				continue
+			}
+			layerSources[envVar] = name
+			values[envVar] = layer.Params[name].Value
+			sources[envVar] = name
+		}
+	}
+
+	var errs []error
+	if len(invalid) > 0 {
+		errs = append(errs, fmt.Errorf("invalid environment variable names, add them to naming.rename: %s", strings.Join(invalid, "; ")))
+	}
+	if len(collisions) > 0 {
+		errs = append(errs, fmt.Errorf("environment variable name collisions: %s", strings.Join(collisions, "; ")))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+	return values, sources, nil
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultPathTemplate is where the parameters of an environment live unless the
+// project config has a path_template
+const DefaultPathTemplate PathTemplate = "{prefix}/{project}/{env}"
+
+// the placeholders every path template can use
+const (
+	PathVarPrefix  = "prefix"
+	PathVarProject = "project"
+	PathVarEnv     = "env"
+)
+
+// TrashEnvironment takes the place of the environment in the path of deleted parameters
+const TrashEnvironment = ".trash"
+
+var pathPlaceholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+var pathVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PathTemplate is where the parameters of an environment live, e.g. /{env}/{team}/{project}.
+// Besides {prefix}, {project} and {env} it can use any variable in path_vars.
+type PathTemplate string
+
+// OrDefault returns the template, or DefaultPathTemplate if it is empty
+func (t PathTemplate) OrDefault() PathTemplate {
+	if t == "" {
+		return DefaultPathTemplate
+	}
+	return t
+}
+
+// Validate returns an error unless the template uses {env} and only uses the
+// placeholders {prefix}, {project}, {env} and the given variables, each once
+func (t PathTemplate) Validate(vars map[string]string) error {
+	seen := make(map[string]bool)
+	for _, match := range pathPlaceholderPattern.FindAllStringSubmatch(string(t), -1) {
+		name := match[1]
+		if !pathVarNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid path template %s, invalid placeholder {%s}", t, name)
+		}
+		_, isVar := vars[name]
+		if name != PathVarPrefix && name != PathVarProject && name != PathVarEnv && !isVar {
+			return fmt.Errorf("invalid path template %s, unknown placeholder {%s}", t, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("invalid path template %s, {%s} is used more than once", t, name)
+		}
+		seen[name] = true
+	}
+
+	if !seen[PathVarEnv] {
+		return fmt.Errorf("invalid path template %s, it must contain {%s}", t, PathVarEnv)
+	}
+	// parameter names are absolute, {prefix} brings its own leading /
+	if !strings.HasPrefix(string(t), KeySeparator) && !strings.HasPrefix(string(t), "{"+PathVarPrefix+"}") {
+		return fmt.Errorf("invalid path template %s, it must start with %s or {%s}", t, KeySeparator, PathVarPrefix)
+	}
+	if strings.Contains(string(t), "//") {
+		return fmt.Errorf("invalid path template %s, it contains an empty level", t)
+	}
+	return nil
+}
+
+// Expand returns the path with every placeholder replaced by its variable
+func (t PathTemplate) Expand(vars map[string]string) string {
+	return pathPlaceholderPattern.ReplaceAllStringFunc(string(t), func(placeholder string) string {
+		return vars[strings.Trim(placeholder, "{}")]
+	})
+}
+
+// Parse is the reverse of Expand. It returns the variables of a path made from the
+// template. {prefix} can span several levels, every other placeholder is one level.
+func (t PathTemplate) Parse(path string) (map[string]string, error) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+
+	last := 0
+	for _, match := range pathPlaceholderPattern.FindAllStringSubmatchIndex(string(t), -1) {
+		pattern.WriteString(regexp.QuoteMeta(string(t[last:match[0]])))
+		name := string(t[match[2]:match[3]])
+		if name == PathVarPrefix {
+			pattern.WriteString("(?P<" + name + ">.*)")
+		} else {
+			pattern.WriteString("(?P<" + name + ">[^/]+)")
+		}
+		last = match[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(string(t[last:])))
+	pattern.WriteString("$")
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template %s: %w", t, err)
+	}
+
+	match := re.FindStringSubmatch(path)
+	if match == nil {
+		return nil, fmt.Errorf("path %s does not match the path template %s", path, t)
+	}
+
+	vars := make(map[string]string)
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			vars[name] = match[i]
+		}
+	}
+	return vars, nil
+}
+
+// pathVars returns the variables of an environment path
+func pathVars(prefix, project, env string, extra map[string]string) map[string]string {
+	vars := make(map[string]string, len(extra)+3)
+	for name, value := range extra {
+		vars[name] = value
+	}
+	vars[PathVarPrefix] = prefix
+	vars[PathVarProject] = project
+	vars[PathVarEnv] = env
+	return vars
+}
+
+// validatePathVars checks a path template along with the values of its placeholders
+func validatePathVars(t PathTemplate, vars map[string]string) error {
+	for name, value := range vars {
+		if name == PathVarPrefix || name == PathVarProject || name == PathVarEnv {
+			return fmt.Errorf("invalid path_vars, {%s} is set by psenv", name)
+		}
+		if value == "" || strings.Contains(value, "/") {
+			return fmt.Errorf("invalid path_vars, %s must be a single non empty level", name)
+		}
+	}
+	return t.OrDefault().Validate(vars)
+}
@@ -0,0 +1,91 @@
+// Package confirm guards destructive operations on protected environments. The
+// environment name has to be typed back, either with --confirm or at a prompt, and a
+// blanket --yes is only accepted when the override variable is set as well.
+package confirm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// OverrideEnvVar lets --yes through on protected environments, e.g. for a CI job
+// that is meant to clean up production
+const OverrideEnvVar = "PSENV_ALLOW_PROTECTED"
+
+// Confirmer decides whether a destructive operation on an environment may go ahead
+type Confirmer struct {
+	// Confirm is the environment name given with --confirm
+	Confirm string
+
+	// Yes is set by --yes
+	Yes bool
+
+	// Override is set when OverrideEnvVar is
+	Override bool
+
+	// In and Out are used to prompt, which only happens when Interactive is set
+	In          io.Reader
+	Out         io.Writer
+	Interactive bool
+
+	// reader is kept across prompts so buffered answers aren't lost
+	reader *bufio.Reader
+}
+
+// New returns a Confirmer that prompts on the terminal when stdin is one
+func New(confirm string, yes bool) *Confirmer {
+	override := os.Getenv(OverrideEnvVar)
+	return &Confirmer{
+		Confirm:     confirm,
+		Yes:         yes,
+		Override:    override != "" && override != "0" && override != "false",
+		In:          os.Stdin,
+		Out:         os.Stderr,
+		Interactive: isTerminal(os.Stdin),
+	}
+}
+
+// Protected returns nil when the action may go ahead on a protected environment.
+// The action describes what is about to happen, e.g. "delete 3 parameters".
+func (c *Confirmer) Protected(env, action string) error {
+	if c.Confirm != "" {
+		if c.Confirm != env {
+			return fmt.Errorf("environment %s is protected and --confirm %s does not match it", env, c.Confirm)
+		}
+		return nil
+	}
+
+	if c.Yes {
+		if !c.Override {
+			return fmt.Errorf("environment %s is protected, --yes is refused unless %s is set, use --confirm %s instead", env, OverrideEnvVar, env)
+		}
+		return nil
+	}
+
+	if !c.Interactive {
+		return fmt.Errorf("environment %s is protected, pass --confirm %s to %s", env, env, action)
+	}
+
+	if c.reader == nil {
+		c.reader = bufio.NewReader(c.In)
+	}
+	fmt.Fprintf(c.Out, "Environment %s is protected. About to %s.\nType the environment name to continue: ", env, action)
+	answer, err := c.reader.ReadString('\n')
+	if err != nil && answer == "" {
+		// stdin looked like a terminal but there is nobody to answer, e.g. /dev/null
+		fmt.Fprintln(c.Out)
+		return fmt.Errorf("environment %s is protected, pass --confirm %s to %s", env, env, action)
+	}
+	if strings.TrimSpace(answer) != env {
+		return fmt.Errorf("environment %s is protected and was not confirmed", env)
+	}
+	return nil
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,46 @@
+package confirm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmFlag(t *testing.T) {
+	require.NoError(t, (&Confirmer{Confirm: "prod"}).Protected("prod", "delete"))
+	require.ErrorContains(t, (&Confirmer{Confirm: "dev"}).Protected("prod", "delete"), "does not match")
+}
+
+func TestYesNeedsOverride(t *testing.T) {
+	require.ErrorContains(t, (&Confirmer{Yes: true}).Protected("prod", "delete"), OverrideEnvVar)
+	require.NoError(t, (&Confirmer{Yes: true, Override: true}).Protected("prod", "delete"))
+}
+
+func TestNonInteractiveRefuses(t *testing.T) {
+	require.ErrorContains(t, (&Confirmer{}).Protected("prod", "delete"), "--confirm prod")
+}
+
+func TestPrompt(t *testing.T) {
+	var out bytes.Buffer
+	c := &Confirmer{In: strings.NewReader("prod\nstaging\n"), Out: &out, Interactive: true}
+
+	require.NoError(t, c.Protected("prod", "delete 2 parameters"))
+	require.Contains(t, out.String(), "About to delete 2 parameters")
+
+	// the second answer is still there for the second prompt
+	require.NoError(t, c.Protected("staging", "delete 1 parameters"))
+	require.ErrorContains(t, c.Protected("prod", "delete"), "pass --confirm prod")
+
+	c = &Confirmer{In: strings.NewReader("dev\n"), Out: &out, Interactive: true}
+	require.ErrorContains(t, c.Protected("prod", "delete"), "was not confirmed")
+}
+
+func TestNewReadsOverride(t *testing.T) {
+	t.Setenv(OverrideEnvVar, "1")
+	require.True(t, New("", true).Override)
+
+	t.Setenv(OverrideEnvVar, "false")
+	require.False(t, New("", true).Override)
+}
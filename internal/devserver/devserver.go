@@ -0,0 +1,240 @@
+// Package devserver is a small in-memory emulator of the parts of the SSM parameter
+// store api that psenv uses. It speaks the SSM json protocol, so psenv, the AWS SDKs
+// and the AWS cli can all be pointed at it with a custom endpoint url.
+package devserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	targetPrefix    = "AmazonSSM."
+	contentType     = "application/x-amz-json-1.1"
+	defaultKeyID    = "alias/aws/ssm"
+	standardMaxSize = 4096
+	advancedMaxSize = 8192
+)
+
+// Server is an http.Handler that emulates the SSM parameter store
+type Server struct {
+	store *store
+	now   func() time.Time
+}
+
+// New creates a dev server. When file is not empty, parameters are loaded from it
+// on start and written back to it after every change.
+func New(file string) (*Server, error) {
+	s, err := newStore(file)
+	if err != nil {
+		return nil, fmt.Errorf("error loading dev server store %s: %s", file, err)
+	}
+	return &Server{store: s, now: time.Now}, nil
+}
+
+// apiError is an error in the format the SSM json protocol returns
+type apiError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Type + ": " + e.Message
+}
+
+func newAPIError(errorType, format string, args ...interface{}) *apiError {
+	return &apiError{Type: errorType, Message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, newAPIError("UnsupportedOperation", "only POST is supported"))
+		return
+	}
+
+	operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix)
+	handler, ok := s.operations()[operation]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, newAPIError("UnknownOperationException", "operation %q is not supported by the psenv dev server", operation))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	result, err := handler(decoder)
+	if err != nil {
+		apiErr, ok := err.(*apiError)
+		if !ok {
+			apiErr = newAPIError("InternalServerError", "%s", err)
+			writeJSON(w, http.StatusInternalServerError, apiErr)
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) operations() map[string]func(*json.Decoder) (interface{}, error) {
+	return map[string]func(*json.Decoder) (interface{}, error){
+		"PutParameter":           s.putParameter,
+		"GetParameter":           s.getParameter,
+		"GetParametersByPath":    s.getParametersByPath,
+		"DeleteParameters":       s.deleteParameters,
+		"DescribeParameters":     s.describeParameters,
+		"GetParameterHistory":    s.getParameterHistory,
+		"AddTagsToResource":      s.addTagsToResource,
+		"RemoveTagsFromResource": s.removeTagsFromResource,
+		"ListTagsForResource":    s.listTagsForResource,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	if apiErr, ok := body.(*apiError); ok {
+		w.Header().Set("X-Amzn-ErrorType", apiErr.Type)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func decode(decoder *json.Decoder, input interface{}) error {
+	if err := decoder.Decode(input); err != nil {
+		return newAPIError("ValidationException", "invalid request body: %s", err)
+	}
+	return nil
+}
+
+// *************** request and response shapes ***************
+
+type tag struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+type inlinePolicy struct {
+	PolicyText   string `json:"PolicyText"`
+	PolicyType   string `json:"PolicyType"`
+	PolicyStatus string `json:"PolicyStatus"`
+}
+
+type parameterOutput struct {
+	Name             string  `json:"Name"`
+	Type             string  `json:"Type"`
+	Value            string  `json:"Value"`
+	Version          int64   `json:"Version"`
+	LastModifiedDate float64 `json:"LastModifiedDate"`
+	DataType         string  `json:"DataType"`
+}
+
+type parameterMetadata struct {
+	Name             string         `json:"Name"`
+	Type             string         `json:"Type"`
+	KeyID            string         `json:"KeyId,omitempty"`
+	Description      string         `json:"Description,omitempty"`
+	Version          int64          `json:"Version"`
+	Tier             string         `json:"Tier"`
+	Policies         []inlinePolicy `json:"Policies"`
+	LastModifiedDate float64        `json:"LastModifiedDate"`
+	DataType         string         `json:"DataType"`
+}
+
+type parameterHistory struct {
+	parameterMetadata
+	Value string `json:"Value"`
+}
+
+type parameterFilter struct {
+	Key    string   `json:"Key"`
+	Option string   `json:"Option"`
+	Values []string `json:"Values"`
+}
+
+func epochSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// encrypt stands in for KMS. Like the real parameter store, secure strings that are
+// read without decryption don't return their plain text value.
+func encrypt(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte("psenv-dev-server:" + value))
+}
+
+func toOutput(name string, v parameterVersion, decrypt bool) parameterOutput {
+	value := v.Value
+	if v.Type == "SecureString" && !decrypt {
+		value = encrypt(value)
+	}
+	return parameterOutput{
+		Name:             name,
+		Type:             v.Type,
+		Value:            value,
+		Version:          v.Version,
+		LastModifiedDate: epochSeconds(v.LastModified),
+		DataType:         "text",
+	}
+}
+
+func toMetadata(name string, v parameterVersion) parameterMetadata {
+	return parameterMetadata{
+		Name:             name,
+		Type:             v.Type,
+		KeyID:            v.KeyID,
+		Description:      v.Description,
+		Version:          v.Version,
+		Tier:             v.Tier,
+		Policies:         inlinePolicies(v.Policies),
+		LastModifiedDate: epochSeconds(v.LastModified),
+		DataType:         "text",
+	}
+}
+
+func inlinePolicies(text string) []inlinePolicy {
+	policies := []inlinePolicy{}
+	if text == "" {
+		return policies
+	}
+
+	var parsed []json.RawMessage
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		return policies
+	}
+	for _, raw := range parsed {
+		var policy struct {
+			Type string `json:"Type"`
+		}
+		json.Unmarshal(raw, &policy)
+		policies = append(policies, inlinePolicy{PolicyText: string(raw), PolicyType: policy.Type, PolicyStatus: "Pending"})
+	}
+	return policies
+}
+
+// paginate returns the start and end index of a page of items, starting at the offset
+// encoded in the next token, along with the token for the following page
+func paginate(total int, nextToken string, maxResults, defaultMax, limit int) (int, int, string, error) {
+	if maxResults == 0 {
+		maxResults = defaultMax
+	}
+	if maxResults < 1 || maxResults > limit {
+		return 0, 0, "", newAPIError("ValidationException", "MaxResults must be between 1 and %d", limit)
+	}
+
+	start := 0
+	if nextToken != "" {
+		var err error
+		start, err = strconv.Atoi(nextToken)
+		if err != nil || start < 0 || start > total {
+			return 0, 0, "", newAPIError("InvalidNextToken", "the next token is not valid")
+		}
+	}
+
+	end := start + maxResults
+	if end >= total {
+		return start, total, "", nil
+	}
+	return start, end, strconv.Itoa(end), nil
+}
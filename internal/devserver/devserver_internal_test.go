@@ -0,0 +1,23 @@
+package devserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginate(t *testing.T) {
+	start, end, next, err := paginate(25, "", 0, 10, 10)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{0, 10, "10"}, []interface{}{start, end, next})
+
+	start, end, next, err = paginate(25, "20", 0, 10, 10)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{20, 25, ""}, []interface{}{start, end, next})
+
+	_, _, _, err = paginate(25, "bogus", 0, 10, 10)
+	require.Error(t, err)
+
+	_, _, _, err = paginate(25, "", 11, 10, 10)
+	require.Error(t, err)
+}
@@ -0,0 +1,190 @@
+package devserver_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pytoolbelt/psenv/internal/devserver/devservertest"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutAndGetParameters(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+
+	_, err := ps.PutParameters(context.Background(), map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEY1":  {Value: "value1"},
+		"/psenv/foobar/dev/HOSTS": {Value: "a,b", Type: types.ParameterTypeStringList},
+		"/psenv/foobar/prod/KEY1": {Value: "prod-value"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	params, err := ps.GetParameters(context.Background(), "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+	require.Len(t, params, 2)
+	require.Equal(t, "value1", params["/psenv/foobar/dev/KEY1"].Value)
+	require.Equal(t, types.ParameterTypeStringList, params["/psenv/foobar/dev/HOSTS"].Type)
+	require.Equal(t, int64(1), params["/psenv/foobar/dev/KEY1"].Version)
+
+	encrypted, err := ps.GetParameters(context.Background(), "/psenv/foobar/dev", false)
+	require.NoError(t, err)
+	require.NotEqual(t, "value1", encrypted["/psenv/foobar/dev/KEY1"].Value)
+	require.Equal(t, "a,b", encrypted["/psenv/foobar/dev/HOSTS"].Value)
+}
+
+func TestPutParameterRequiresOverwrite(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+	params := map[string]parameterstore.Parameter{"/psenv/foobar/dev/KEY1": {Value: "value1"}}
+
+	_, err := ps.PutParameters(context.Background(), params, "alias/aws/ssm", false)
+	require.NoError(t, err)
+	_, err = ps.PutParameters(context.Background(), params, "alias/aws/ssm", false)
+	require.Error(t, err)
+	versions, err := ps.PutParameters(context.Background(), params, "alias/aws/ssm", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), versions["/psenv/foobar/dev/KEY1"])
+
+	result, err := ps.GetParameters(context.Background(), "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result["/psenv/foobar/dev/KEY1"].Version)
+}
+
+func TestMetadataAndSearch(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+
+	_, err := ps.PutParameters(context.Background(), map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEY1": {
+			Value:       "value1",
+			Description: "the first key",
+			Tags:        map[string]string{parameterstore.OwnerTagKey: "payments"},
+			Policies:    parameterstore.Policies{Expiration: "2030-01-01T00:00:00.000Z"},
+		},
+		"/psenv/foobar/dev/KEY2": {Value: "value2"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	params, err := ps.GetParametersWithMetadata(context.Background(), "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+
+	key1 := params["/psenv/foobar/dev/KEY1"]
+	require.Equal(t, "the first key", key1.Description)
+	require.Equal(t, "payments", key1.Tags[parameterstore.OwnerTagKey])
+	require.Equal(t, types.ParameterTierAdvanced, key1.Tier)
+	require.Equal(t, "2030-01-01T00:00:00.000Z", key1.Policies.Expiration)
+	require.Equal(t, types.ParameterTierStandard, params["/psenv/foobar/dev/KEY2"].Tier)
+
+	found, err := ps.SearchByTags(context.Background(), "/psenv/foobar", map[string]string{parameterstore.OwnerTagKey: "payments"})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, "/psenv/foobar/dev/KEY1", found[0].Name)
+}
+
+func TestAdvancedParametersStayAdvanced(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+	ctx := context.Background()
+
+	_, err := ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEY1": {Value: "value1", Policies: parameterstore.Policies{Expiration: "2030-01-01T00:00:00.000Z"}},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	// like the parameter store, a downgrade is rejected
+	_, err = ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEY1": {Value: "value1", Tier: types.ParameterTierStandard},
+	}, "alias/aws/ssm", true)
+	require.ErrorContains(t, err, "downgrade")
+
+	// an empty list of policies clears them and the parameter stays advanced
+	_, err = ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEY1": {Value: "value1", Tier: types.ParameterTierAdvanced},
+	}, "alias/aws/ssm", true)
+	require.NoError(t, err)
+
+	params, err := ps.GetParametersWithMetadata(ctx, "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+	require.Equal(t, types.ParameterTierAdvanced, params["/psenv/foobar/dev/KEY1"].Tier)
+	require.True(t, params["/psenv/foobar/dev/KEY1"].Policies.IsEmpty())
+}
+
+func TestDescribeAndDeleteParameters(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+
+	_, err := ps.PutParameters(context.Background(), map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEY1": {Value: "value1"},
+		"/psenv/foobar/dev/KEY2": {Value: "value2"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	names, err := ps.DescribeParameters(context.Background(), "/psenv/foobar/dev")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"/psenv/foobar/dev/KEY1", "/psenv/foobar/dev/KEY2"}, names)
+
+	require.NoError(t, ps.DeleteParameters(context.Background(), names))
+
+	params, err := ps.GetParameters(context.Background(), "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+	require.Empty(t, params)
+}
+
+func TestParameterHistory(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+	client := ps.Client.(*ssm.Client)
+
+	for _, value := range []string{"one", "two"} {
+		_, err := ps.PutParameters(context.Background(), map[string]parameterstore.Parameter{"/psenv/foobar/dev/KEY1": {Value: value}}, "alias/aws/ssm", true)
+		require.NoError(t, err)
+	}
+
+	result, err := client.GetParameterHistory(context.Background(), &ssm.GetParameterHistoryInput{
+		Name:           aws.String("/psenv/foobar/dev/KEY1"),
+		WithDecryption: aws.Bool(true),
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Parameters, 2)
+	require.Equal(t, "one", *result.Parameters[0].Value)
+	require.Equal(t, int64(2), result.Parameters[1].Version)
+}
+
+func TestParametersArePersisted(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "parameters.json")
+
+	ps := devservertest.Connect(t, devservertest.Start(t, file))
+	_, err := ps.PutParameters(context.Background(), map[string]parameterstore.Parameter{"/psenv/foobar/dev/KEY1": {Value: "value1"}}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	restarted := devservertest.Connect(t, devservertest.Start(t, file))
+	params, err := restarted.GetParameters(context.Background(), "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+	require.Equal(t, "value1", params["/psenv/foobar/dev/KEY1"].Value)
+}
+
+func TestDescribeAndDeleteManyParameters(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+
+	params := make(map[string]parameterstore.Parameter)
+	for i := 0; i < 60; i++ {
+		params[fmt.Sprintf("/psenv/foobar/dev/KEY%02d", i)] = parameterstore.Parameter{Value: "value"}
+	}
+	_, err := ps.PutParameters(context.Background(), params, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	names, err := ps.DescribeParameters(context.Background(), "/psenv/foobar/dev/")
+	require.NoError(t, err)
+	require.Len(t, names, 60)
+
+	require.NoError(t, ps.DeleteParameters(context.Background(), names))
+
+	names, err = ps.DescribeParameters(context.Background(), "/psenv/foobar/dev/")
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	err = ps.DeleteParameters(context.Background(), []string{"/psenv/foobar/dev/KEY00"})
+	var deleteErr *parameterstore.DeleteParametersError
+	require.ErrorAs(t, err, &deleteErr)
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY00"}, deleteErr.Invalid)
+}
@@ -0,0 +1,55 @@
+// Package devservertest runs a dev server for the duration of a test, so tests can
+// read and write parameters without an AWS account.
+package devservertest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pytoolbelt/psenv/internal/devserver"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+)
+
+// Region and the static credentials the clients of a dev server use
+const (
+	Region          = "us-east-1"
+	AccessKeyID     = "test"
+	SecretAccessKey = "test"
+)
+
+// Start runs a dev server until the test ends and returns its url. Parameters are
+// persisted to file, or only kept in memory when it is empty.
+func Start(t testing.TB, file string) string {
+	t.Helper()
+	server, err := devserver.New(file)
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL
+}
+
+// ClientOptions returns the options of a parameter store client for the dev server at url
+func ClientOptions(url string) []parameterstore.Option {
+	return []parameterstore.Option{
+		parameterstore.WithEndpointURL(url),
+		parameterstore.WithRegion(Region),
+		parameterstore.WithStaticCredentials(AccessKeyID, SecretAccessKey),
+	}
+}
+
+// Connect returns a parameter store client for the dev server at url
+func Connect(t testing.TB, url string) *parameterstore.ParameterStore {
+	t.Helper()
+	ps, err := parameterstore.New(ClientOptions(url)...)
+	require.NoError(t, err)
+	return ps
+}
+
+// NewParameterStore runs an in-memory dev server until the test ends and returns a
+// parameter store client for it
+func NewParameterStore(t testing.TB) *parameterstore.ParameterStore {
+	t.Helper()
+	return Connect(t, Start(t, ""))
+}
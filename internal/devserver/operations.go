@@ -0,0 +1,445 @@
+package devserver
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+func (s *Server) putParameter(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		Name        string  `json:"Name"`
+		Value       string  `json:"Value"`
+		Type        string  `json:"Type"`
+		Description *string `json:"Description"`
+		KeyID       string  `json:"KeyId"`
+		Overwrite   bool    `json:"Overwrite"`
+		Tier        string  `json:"Tier"`
+		Policies    string  `json:"Policies"`
+		Tags        []tag   `json:"Tags"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	// an empty list of policies clears them
+	if input.Policies == "[]" {
+		input.Policies = ""
+	}
+
+	if input.Name == "" || input.Value == "" {
+		return nil, newAPIError("ValidationException", "Name and Value are required")
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	existing, exists := s.store.parameters[input.Name]
+	if exists && !input.Overwrite {
+		return nil, newAPIError("ParameterAlreadyExists", "The parameter already exists. To overwrite this value, set the overwrite option in the request to true.")
+	}
+	if exists && len(input.Tags) > 0 {
+		return nil, newAPIError("ValidationException", "Tags and Overwrite can't be used together. To create a parameter with tags, please remove overwrite flag. To update tags for an existing parameter, please use AddTagsToResource or RemoveTagsFromResource.")
+	}
+
+	version := parameterVersion{
+		Value:        input.Value,
+		Type:         input.Type,
+		Tier:         input.Tier,
+		Policies:     input.Policies,
+		Version:      1,
+		LastModified: s.now(),
+	}
+
+	if input.Description != nil {
+		version.Description = *input.Description
+	}
+
+	if exists {
+		current := existing.current()
+		version.Version = current.Version + 1
+		if version.Type == "" {
+			version.Type = current.Type
+		}
+		// like the parameter store, an omitted description is kept and an empty one clears it
+		if input.Description == nil {
+			version.Description = current.Description
+		}
+		// the parameter store never moves a parameter back to the standard tier, asking
+		// for it is an error while intelligent tiering keeps it advanced
+		if current.Tier == "Advanced" {
+			if version.Tier == "Standard" {
+				return nil, newAPIError("ValidationException", "This parameter uses the advanced-parameter tier. You can't downgrade a parameter from the advanced-parameter tier to the standard-parameter tier.")
+			}
+			version.Tier = "Advanced"
+		}
+	}
+
+	switch version.Type {
+	case "String", "StringList":
+	case "SecureString":
+		version.KeyID = input.KeyID
+		if version.KeyID == "" {
+			version.KeyID = defaultKeyID
+		}
+	case "":
+		return nil, newAPIError("ValidationException", "A parameter type is required when you create a parameter.")
+	default:
+		return nil, newAPIError("ValidationException", "Type %q is not one of String, StringList or SecureString", version.Type)
+	}
+
+	switch version.Tier {
+	case "", "Standard":
+		version.Tier = "Standard"
+	case "Intelligent-Tiering":
+		version.Tier = "Standard"
+		if len(version.Value) > standardMaxSize || version.Policies != "" {
+			version.Tier = "Advanced"
+		}
+	case "Advanced":
+	default:
+		return nil, newAPIError("ValidationException", "Tier %q is not one of Standard, Advanced or Intelligent-Tiering", version.Tier)
+	}
+
+	if version.Tier == "Standard" && len(version.Value) > standardMaxSize {
+		return nil, newAPIError("ValidationException", "Standard tier parameters support a maximum parameter value of %d characters.", standardMaxSize)
+	}
+	if len(version.Value) > advancedMaxSize {
+		return nil, newAPIError("ValidationException", "Advanced tier parameters support a maximum parameter value of %d characters.", advancedMaxSize)
+	}
+	if version.Tier == "Standard" && version.Policies != "" {
+		return nil, newAPIError("ValidationException", "Parameter policies are only supported for advanced tier parameters.")
+	}
+
+	if !exists {
+		existing = &parameter{Name: input.Name}
+		s.store.parameters[input.Name] = existing
+	}
+	existing.Versions = append(existing.Versions, version)
+	for _, t := range input.Tags {
+		if existing.Tags == nil {
+			existing.Tags = make(map[string]string)
+		}
+		existing.Tags[t.Key] = t.Value
+	}
+
+	if err := s.store.save(); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"Version": version.Version, "Tier": version.Tier}, nil
+}
+
+func (s *Server) getParameter(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		Name           string `json:"Name"`
+		WithDecryption bool   `json:"WithDecryption"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	p, ok := s.store.parameters[input.Name]
+	if !ok {
+		return nil, newAPIError("ParameterNotFound", "Parameter %s not found.", input.Name)
+	}
+	return map[string]interface{}{"Parameter": toOutput(p.Name, p.current(), input.WithDecryption)}, nil
+}
+
+func (s *Server) getParametersByPath(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		Path           string `json:"Path"`
+		Recursive      bool   `json:"Recursive"`
+		WithDecryption bool   `json:"WithDecryption"`
+		MaxResults     int    `json:"MaxResults"`
+		NextToken      string `json:"NextToken"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(input.Path, "/") {
+		return nil, newAPIError("ValidationException", "The path must begin with a forward slash.")
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	var matches []*parameter
+	for _, p := range s.store.sorted() {
+		if isBelow(p.Name, input.Path, input.Recursive) {
+			matches = append(matches, p)
+		}
+	}
+
+	start, end, next, err := paginate(len(matches), input.NextToken, input.MaxResults, 10, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := []parameterOutput{}
+	for _, p := range matches[start:end] {
+		parameters = append(parameters, toOutput(p.Name, p.current(), input.WithDecryption))
+	}
+	return withNextToken(map[string]interface{}{"Parameters": parameters}, next), nil
+}
+
+func (s *Server) deleteParameters(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		Names []string `json:"Names"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	if len(input.Names) < 1 || len(input.Names) > 10 {
+		return nil, newAPIError("ValidationException", "1 validation error detected: Value at 'names' failed to satisfy constraint: Member must have length less than or equal to 10")
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	deleted := []string{}
+	invalid := []string{}
+	for _, name := range input.Names {
+		if _, ok := s.store.parameters[name]; !ok {
+			invalid = append(invalid, name)
+			continue
+		}
+		delete(s.store.parameters, name)
+		deleted = append(deleted, name)
+	}
+
+	if err := s.store.save(); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"DeletedParameters": deleted, "InvalidParameters": invalid}, nil
+}
+
+func (s *Server) describeParameters(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		ParameterFilters []parameterFilter `json:"ParameterFilters"`
+		MaxResults       int               `json:"MaxResults"`
+		NextToken        string            `json:"NextToken"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	var matches []*parameter
+	for _, p := range s.store.sorted() {
+		ok, err := matchesFilters(p, input.ParameterFilters)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, p)
+		}
+	}
+
+	start, end, next, err := paginate(len(matches), input.NextToken, input.MaxResults, 50, 50)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := []parameterMetadata{}
+	for _, p := range matches[start:end] {
+		parameters = append(parameters, toMetadata(p.Name, p.current()))
+	}
+	return withNextToken(map[string]interface{}{"Parameters": parameters}, next), nil
+}
+
+func (s *Server) getParameterHistory(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		Name           string `json:"Name"`
+		WithDecryption bool   `json:"WithDecryption"`
+		MaxResults     int    `json:"MaxResults"`
+		NextToken      string `json:"NextToken"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	p, ok := s.store.parameters[input.Name]
+	if !ok {
+		return nil, newAPIError("ParameterNotFound", "Parameter %s not found.", input.Name)
+	}
+
+	start, end, next, err := paginate(len(p.Versions), input.NextToken, input.MaxResults, 50, 50)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []parameterHistory{}
+	for _, v := range p.Versions[start:end] {
+		history = append(history, parameterHistory{
+			parameterMetadata: toMetadata(p.Name, v),
+			Value:             toOutput(p.Name, v, input.WithDecryption).Value,
+		})
+	}
+	return withNextToken(map[string]interface{}{"Parameters": history}, next), nil
+}
+
+func (s *Server) addTagsToResource(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		ResourceType string `json:"ResourceType"`
+		ResourceID   string `json:"ResourceId"`
+		Tags         []tag  `json:"Tags"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	p, err := s.taggedParameter(input.ResourceType, input.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range input.Tags {
+		if p.Tags == nil {
+			p.Tags = make(map[string]string)
+		}
+		p.Tags[t.Key] = t.Value
+	}
+
+	if err := s.store.save(); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{}, nil
+}
+
+func (s *Server) removeTagsFromResource(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		ResourceType string   `json:"ResourceType"`
+		ResourceID   string   `json:"ResourceId"`
+		TagKeys      []string `json:"TagKeys"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	p, err := s.taggedParameter(input.ResourceType, input.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range input.TagKeys {
+		delete(p.Tags, key)
+	}
+
+	if err := s.store.save(); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{}, nil
+}
+
+func (s *Server) listTagsForResource(decoder *json.Decoder) (interface{}, error) {
+	var input struct {
+		ResourceType string `json:"ResourceType"`
+		ResourceID   string `json:"ResourceId"`
+	}
+	if err := decode(decoder, &input); err != nil {
+		return nil, err
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	p, err := s.taggedParameter(input.ResourceType, input.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []tag{}
+	for key, value := range p.Tags {
+		tags = append(tags, tag{Key: key, Value: value})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return map[string]interface{}{"TagList": tags}, nil
+}
+
+// taggedParameter returns the parameter a tagging request is for. It must be called with the lock held.
+func (s *Server) taggedParameter(resourceType, resourceID string) (*parameter, error) {
+	if resourceType != "Parameter" {
+		return nil, newAPIError("InvalidResourceType", "the psenv dev server only supports tagging parameters")
+	}
+	p, ok := s.store.parameters[resourceID]
+	if !ok {
+		return nil, newAPIError("InvalidResourceId", "The resource ID %s is not valid.", resourceID)
+	}
+	return p, nil
+}
+
+func withNextToken(output map[string]interface{}, next string) map[string]interface{} {
+	if next != "" {
+		output["NextToken"] = next
+	}
+	return output
+}
+
+// matchesFilters reports whether a parameter matches every describe filter
+func matchesFilters(p *parameter, filters []parameterFilter) (bool, error) {
+	current := p.current()
+
+	for _, filter := range filters {
+		var matches func(value string) bool
+
+		switch {
+		case filter.Key == "Name":
+			matches = func(value string) bool {
+				switch filter.Option {
+				case "BeginsWith":
+					return strings.HasPrefix(p.Name, value)
+				case "Contains":
+					return strings.Contains(p.Name, value)
+				default:
+					return p.Name == value
+				}
+			}
+		case filter.Key == "Path":
+			matches = func(value string) bool {
+				return isBelow(p.Name, value, filter.Option == "Recursive")
+			}
+		case filter.Key == "Type":
+			matches = func(value string) bool { return current.Type == value }
+		case filter.Key == "Tier":
+			matches = func(value string) bool { return current.Tier == value }
+		case filter.Key == "KeyId":
+			matches = func(value string) bool { return current.KeyID == value }
+		case strings.HasPrefix(filter.Key, "tag:"):
+			tagValue, tagged := p.Tags[strings.TrimPrefix(filter.Key, "tag:")]
+			matches = func(value string) bool { return tagged && tagValue == value }
+			if len(filter.Values) == 0 && tagged {
+				continue
+			}
+		default:
+			return false, newAPIError("InvalidFilterKey", "The filter key %s is not supported by the psenv dev server.", filter.Key)
+		}
+
+		matched := false
+		for _, value := range filter.Values {
+			if matches(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package devserver
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parameterVersion is a single version of a parameter
+type parameterVersion struct {
+	Value        string    `json:"value"`
+	Type         string    `json:"type"`
+	Description  string    `json:"description,omitempty"`
+	KeyID        string    `json:"key_id,omitempty"`
+	Tier         string    `json:"tier"`
+	Policies     string    `json:"policies,omitempty"`
+	Version      int64     `json:"version"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// parameter is a parameter with every version it has had, newest last
+type parameter struct {
+	Name     string             `json:"name"`
+	Tags     map[string]string  `json:"tags,omitempty"`
+	Versions []parameterVersion `json:"versions"`
+}
+
+func (p *parameter) current() parameterVersion {
+	return p.Versions[len(p.Versions)-1]
+}
+
+// store holds the parameters in memory and optionally persists them to a json file
+type store struct {
+	mu         sync.Mutex
+	parameters map[string]*parameter
+	file       string
+}
+
+func newStore(file string) (*store, error) {
+	s := &store{
+		parameters: make(map[string]*parameter),
+		file:       file,
+	}
+	if file == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var parameters []*parameter
+	if err := json.Unmarshal(data, &parameters); err != nil {
+		return nil, err
+	}
+	for _, p := range parameters {
+		s.parameters[p.Name] = p
+	}
+	return s, nil
+}
+
+// save writes the parameters to the store file. It must be called with the lock held.
+func (s *store) save() error {
+	if s.file == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never leaves a half written store
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), ".psenv-dev-server-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// sorted returns all parameters sorted by name. It must be called with the lock held.
+func (s *store) sorted() []*parameter {
+	parameters := make([]*parameter, 0, len(s.parameters))
+	for _, p := range s.parameters {
+		parameters = append(parameters, p)
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		return parameters[i].Name < parameters[j].Name
+	})
+	return parameters
+}
+
+// isBelow reports whether a parameter name is below a path, either directly or at any depth
+func isBelow(name, path string, recursive bool) bool {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	return recursive || !strings.Contains(strings.TrimPrefix(name, prefix), "/")
+}
@@ -0,0 +1,206 @@
+// Package engine runs parameter store operations across the environments of a project.
+// Environments are processed by a bounded pool of workers, and every environment
+// gets its own Result, so one failing environment never hides the others.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+)
+
+// Engine runs operations against the parameter store, one environment per worker
+type Engine struct {
+	// NewClient creates the parameter store client for an environment
+	NewClient func(env string) (*parameterstore.ParameterStore, error)
+
+	// Path returns the parameter store path of an environment
+	Path func(env string) string
+
+	// Workers is the most environments processed at the same time.
+	// Zero runs one worker per environment.
+	Workers int
+
+	// Out receives a summary line per environment. Nil discards them.
+	Out io.Writer
+}
+
+// Result is the outcome of an operation for a single environment
+type Result struct {
+	Env string
+
+	// Path is where the parameters of the environment live. Parameters can be nested
+	// any number of levels below it.
+	Path string
+
+	// Params holds the parameters read from the environment. After a put these are
+	// the parameters read back once every write was verified.
+	Params map[string]parameterstore.Parameter
+
+	Added   []string
+	Updated []string
+	Deleted []string
+
+	// Rollback is set when a put failed and was rolled back
+	Rollback *Rollback
+
+	Err error
+}
+
+// Results holds one Result per environment, in the order the environments were given
+type Results []Result
+
+// Err joins the errors of every environment that failed, or returns nil if none did
+func (r Results) Err() error {
+	var errs []error
+	for _, result := range r {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Env, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Finished returns the environments that completed without an error
+func (r Results) Finished() []string {
+	var envs []string
+	for _, result := range r {
+		if result.Err == nil {
+			envs = append(envs, result.Env)
+		}
+	}
+	return envs
+}
+
+// Unfinished returns the environments that failed or were never started
+func (r Results) Unfinished() []string {
+	var envs []string
+	for _, result := range r {
+		if result.Err != nil {
+			envs = append(envs, result.Env)
+		}
+	}
+	return envs
+}
+
+// Params merges the parameters of every environment that finished
+func (r Results) Params() map[string]parameterstore.Parameter {
+	params := make(map[string]parameterstore.Parameter)
+	for _, result := range r {
+		if result.Err != nil {
+			continue
+		}
+		for name, param := range result.Params {
+			params[name] = param
+		}
+	}
+	return params
+}
+
+// run calls fn for every environment on a bounded pool of workers. Environments that
+// are still queued when the context is cancelled fail with the context error.
+func (e *Engine) run(ctx context.Context, envs []string, fn func(ctx context.Context, ps *parameterstore.ParameterStore, result *Result) error) Results {
+	results := make(Results, len(envs))
+
+	workers := e.Workers
+	if workers <= 0 || workers > len(envs) {
+		workers = len(envs)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result := &results[index]
+				if err := ctx.Err(); err != nil {
+					result.Err = err
+					continue
+				}
+
+				ps, err := e.NewClient(result.Env)
+				if err != nil {
+					result.Err = err
+					continue
+				}
+				result.Err = fn(ctx, ps, result)
+			}
+		}()
+	}
+
+	for i, env := range envs {
+		results[i].Env = env
+		results[i].Path = e.Path(env)
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func (e *Engine) printf(format string, args ...interface{}) {
+	if e.Out != nil {
+		fmt.Fprintf(e.Out, format, args...)
+	}
+}
+
+// GetOptions control how parameters are read
+type GetOptions struct {
+	Decrypt bool
+
+	// WithMetadata also reads descriptions, tags, tiers and policies
+	WithMetadata bool
+}
+
+// Get reads the parameters of every environment
+func (e *Engine) Get(ctx context.Context, envs []string, opts GetOptions) Results {
+	return e.run(ctx, envs, func(ctx context.Context, ps *parameterstore.ParameterStore, result *Result) error {
+		params, err := read(ctx, ps, result.Path, opts)
+		if err != nil {
+			return err
+		}
+		result.Params = params
+		return nil
+	})
+}
+
+func read(ctx context.Context, ps *parameterstore.ParameterStore, path string, opts GetOptions) (map[string]parameterstore.Parameter, error) {
+	if opts.WithMetadata {
+		return ps.GetParametersWithMetadata(ctx, path, opts.Decrypt)
+	}
+	return ps.GetParameters(ctx, path, opts.Decrypt)
+}
+
+// Search finds the parameters of every environment that have all of the tags
+func (e *Engine) Search(ctx context.Context, envs []string, tags map[string]string) Results {
+	return e.run(ctx, envs, func(ctx context.Context, ps *parameterstore.ParameterStore, result *Result) error {
+		found, err := ps.SearchByTags(ctx, result.Path, tags)
+		if err != nil {
+			return err
+		}
+		result.Params = make(map[string]parameterstore.Parameter)
+		for _, param := range found {
+			result.Params[param.Name] = param
+		}
+		return nil
+	})
+}
+
+// sortedEnvs returns the environments of a map in a stable order
+func sortedEnvs[V any](m map[string]V) []string {
+	envs := make([]string, 0, len(m))
+	for env := range m {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	return envs
+}
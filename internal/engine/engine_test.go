@@ -0,0 +1,395 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pytoolbelt/psenv/internal/devserver/devservertest"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEngine(t *testing.T) *Engine {
+	url := devservertest.Start(t, "")
+
+	return &Engine{
+		NewClient: func(env string) (*parameterstore.ParameterStore, error) {
+			return parameterstore.New(devservertest.ClientOptions(url)...)
+		},
+		Path: func(env string) string {
+			return "/psenv/foobar/" + env
+		},
+		Workers: 2,
+	}
+}
+
+func TestPutAddsUpdatesAndDeletes(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "value1"},
+			"/psenv/foobar/dev/KEY2": {Value: "value2"},
+		},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY1", "/psenv/foobar/dev/KEY2"}, results[0].Added)
+
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "changed"},
+		},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+
+	result := results[0]
+	require.Empty(t, result.Added)
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY1"}, result.Updated)
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY2"}, result.Deleted)
+
+	// the result holds the parameters as read back after the writes were verified
+	require.Len(t, result.Params, 1)
+	require.Equal(t, int64(2), result.Params["/psenv/foobar/dev/KEY1"].Version)
+	require.Equal(t, "changed", result.Params["/psenv/foobar/dev/KEY1"].Value)
+
+	// unchanged secure strings are left alone
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "changed"},
+		},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+	require.Empty(t, results[0].Updated)
+}
+
+func TestGetKeepsResultsOfOtherEnvironments(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev":  {"/psenv/foobar/dev/KEY": {Value: "dev"}},
+		"prod": {"/psenv/foobar/prod/KEY": {Value: "prod"}},
+	}, PutOptions{})
+	require.NoError(t, results.Err())
+
+	newClient := e.NewClient
+	e.NewClient = func(env string) (*parameterstore.ParameterStore, error) {
+		if env == "broken" {
+			return nil, errors.New("no credentials")
+		}
+		return newClient(env)
+	}
+
+	results = e.Get(ctx, []string{"dev", "broken", "prod"}, GetOptions{Decrypt: true})
+	require.ErrorContains(t, results.Err(), "broken: no credentials")
+	require.Equal(t, []string{"dev", "prod"}, results.Finished())
+	require.Equal(t, []string{"broken"}, results.Unfinished())
+	require.Equal(t, "prod", results.Params()["/psenv/foobar/prod/KEY"].Value)
+}
+
+func TestCancelledContextLeavesEnvironmentsUnfinished(t *testing.T) {
+	e := newTestEngine(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := e.Get(ctx, []string{"dev", "prod"}, GetOptions{})
+	require.ErrorIs(t, results.Err(), context.Canceled)
+	require.Empty(t, results.Finished())
+	require.Equal(t, []string{"dev", "prod"}, results.Unfinished())
+}
+
+func TestPutConfirmsDeletes(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"prod": {
+			"/psenv/foobar/prod/KEY1": {Value: "value1"},
+			"/psenv/foobar/prod/KEY2": {Value: "value2"},
+		},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+
+	var asked []string
+	refuse := func(env string, names []string) error {
+		asked = append(asked, names...)
+		return errors.New("not confirmed")
+	}
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"prod": {"/psenv/foobar/prod/KEY1": {Value: "changed"}},
+	}, PutOptions{Overwrite: true, ConfirmDelete: refuse})
+	require.ErrorContains(t, results.Err(), "not confirmed")
+	require.Equal(t, []string{"/psenv/foobar/prod/KEY2"}, asked)
+
+	// nothing was written, not even the update
+	results = e.Get(ctx, []string{"prod"}, GetOptions{Decrypt: true})
+	require.NoError(t, results.Err())
+	require.Equal(t, map[string]string{
+		"/psenv/foobar/prod/KEY1": "value1",
+		"/psenv/foobar/prod/KEY2": "value2",
+	}, parameterstore.Values(results.Params()))
+
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"prod": {"/psenv/foobar/prod/KEY1": {Value: "changed"}},
+	}, PutOptions{Overwrite: true, ConfirmDelete: func(string, []string) error { return nil }})
+	require.NoError(t, results.Err())
+	require.Equal(t, []string{"/psenv/foobar/prod/KEY2"}, results[0].Deleted)
+}
+
+func TestPutTrashesDeletes(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "value1"},
+			"/psenv/foobar/dev/KEY2": {Value: "value2", Type: "SecureString"},
+		},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+
+	var trashed map[string]parameterstore.Parameter
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {"/psenv/foobar/dev/KEY1": {Value: "value1"}},
+	}, PutOptions{Overwrite: true, Trash: func(ctx context.Context, ps *parameterstore.ParameterStore, env, path string, params map[string]parameterstore.Parameter) (func(context.Context) error, error) {
+		require.Equal(t, "dev", env)
+		require.Equal(t, "/psenv/foobar/dev", path)
+		trashed = params
+		return nil, nil
+	}})
+	require.NoError(t, results.Err())
+	require.Len(t, trashed, 1)
+	require.Equal(t, "value2", trashed["/psenv/foobar/dev/KEY2"].Value)
+
+	// a failing trash leaves the environment untouched
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {},
+	}, PutOptions{Overwrite: true, Trash: func(context.Context, *parameterstore.ParameterStore, string, string, map[string]parameterstore.Parameter) (func(context.Context) error, error) {
+		return nil, errors.New("trash is full")
+	}})
+	require.ErrorContains(t, results.Err(), "trash is full")
+
+	results = e.Get(ctx, []string{"dev"}, GetOptions{Decrypt: true})
+	require.NoError(t, results.Err())
+	require.Equal(t, map[string]string{"/psenv/foobar/dev/KEY1": "value1"}, parameterstore.Values(results.Params()))
+}
+
+// failingClient fails the first put of one parameter
+type failingClient struct {
+	parameterstore.SSMClient
+	name   string
+	failed bool
+}
+
+func (c *failingClient) PutParameter(ctx context.Context, input *ssm.PutParameterInput, opts ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	if aws.ToString(input.Name) == c.name && !c.failed {
+		c.failed = true
+		return nil, errors.New("throttled")
+	}
+	return c.SSMClient.PutParameter(ctx, input, opts...)
+}
+
+func TestPutRollsBackFailedEnvironment(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "value1", Description: "first"},
+			"/psenv/foobar/dev/KEY2": {Value: "value2"},
+			"/psenv/foobar/dev/KEY3": {Value: "value3"},
+		},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+
+	newClient := e.NewClient
+	e.NewClient = func(env string) (*parameterstore.ParameterStore, error) {
+		ps, err := newClient(env)
+		if err != nil {
+			return nil, err
+		}
+		ps.Client = &failingClient{SSMClient: ps.Client, name: "/psenv/foobar/dev/KEY3"}
+		return ps, nil
+	}
+
+	// KEY0 is added and KEY1 updated before KEY3 fails, KEY2 is never deleted
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY0": {Value: "new"},
+			"/psenv/foobar/dev/KEY1": {Value: "changed"},
+			"/psenv/foobar/dev/KEY3": {Value: "changed"},
+		},
+	}, PutOptions{Overwrite: true, Rollback: true})
+	require.ErrorContains(t, results.Err(), "throttled")
+	require.Empty(t, results[0].Deleted)
+
+	rollback := results[0].Rollback
+	require.NotNil(t, rollback)
+	require.NoError(t, rollback.Err)
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY0"}, rollback.Removed)
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY1", "/psenv/foobar/dev/KEY3"}, rollback.Restored)
+	require.Empty(t, rollback.Failed)
+
+	e.NewClient = newClient
+	results = e.Get(ctx, []string{"dev"}, GetOptions{Decrypt: true, WithMetadata: true})
+	require.NoError(t, results.Err())
+	params := results.Params()
+	require.Equal(t, map[string]string{
+		"/psenv/foobar/dev/KEY1": "value1",
+		"/psenv/foobar/dev/KEY2": "value2",
+		"/psenv/foobar/dev/KEY3": "value3",
+	}, parameterstore.Values(params))
+	require.Equal(t, "first", params["/psenv/foobar/dev/KEY1"].Description)
+}
+
+func TestPutRollbackRestoresKeysTagsAndTrash(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "value1", Tags: map[string]string{"team": "platform"}},
+			"/psenv/foobar/dev/KEY2": {Value: "value2"},
+			"/psenv/foobar/dev/KEY3": {Value: "value3"},
+		},
+	}, PutOptions{KeyID: func(string) string { return "alias/old" }, Overwrite: true})
+	require.NoError(t, results.Err())
+
+	newClient := e.NewClient
+	e.NewClient = func(env string) (*parameterstore.ParameterStore, error) {
+		ps, err := newClient(env)
+		if err != nil {
+			return nil, err
+		}
+		ps.Client = &failingClient{SSMClient: ps.Client, name: "/psenv/foobar/dev/KEY3"}
+		return ps, nil
+	}
+
+	// KEY1 is re-encrypted and tagged and KEY2 trashed before KEY3 fails
+	untrashed := false
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "changed", Tags: map[string]string{"team": "platform", "owner": "me"}},
+			"/psenv/foobar/dev/KEY3": {Value: "changed"},
+		},
+	}, PutOptions{
+		KeyID:     func(string) string { return "alias/new" },
+		Overwrite: true,
+		Rollback:  true,
+		Trash: func(context.Context, *parameterstore.ParameterStore, string, string, map[string]parameterstore.Parameter) (func(context.Context) error, error) {
+			return func(context.Context) error {
+				untrashed = true
+				return nil
+			}, nil
+		},
+	})
+	require.ErrorContains(t, results.Err(), "throttled")
+	require.NoError(t, results[0].Rollback.Err)
+	require.True(t, untrashed)
+
+	e.NewClient = newClient
+	results = e.Get(ctx, []string{"dev"}, GetOptions{Decrypt: true, WithMetadata: true})
+	require.NoError(t, results.Err())
+	param := results.Params()["/psenv/foobar/dev/KEY1"]
+	require.Equal(t, "value1", param.Value)
+	require.Equal(t, "alias/old", param.KeyID)
+	require.Equal(t, map[string]string{"team": "platform"}, param.Tags)
+}
+
+func TestPutRollbackOfAdvancedUpdate(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: "value1"},
+			"/psenv/foobar/dev/KEY2": {Value: "value2", Tier: types.ParameterTierAdvanced},
+			"/psenv/foobar/dev/KEY3": {Value: "value3"},
+		},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+
+	newClient := e.NewClient
+	e.NewClient = func(env string) (*parameterstore.ParameterStore, error) {
+		ps, err := newClient(env)
+		if err != nil {
+			return nil, err
+		}
+		ps.Client = &failingClient{SSMClient: ps.Client, name: "/psenv/foobar/dev/KEY3"}
+		return ps, nil
+	}
+
+	// KEY1 grows into the advanced tier before KEY3 fails
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {
+			"/psenv/foobar/dev/KEY1": {Value: strings.Repeat("x", parameterstore.StandardTierMaxSize+1)},
+			"/psenv/foobar/dev/KEY2": {Value: "changed", Tier: types.ParameterTierAdvanced},
+			"/psenv/foobar/dev/KEY3": {Value: "changed"},
+		},
+	}, PutOptions{Overwrite: true, Rollback: true})
+	require.ErrorContains(t, results.Err(), "throttled")
+	rollback := results[0].Rollback
+	require.NoError(t, rollback.Err)
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY1", "/psenv/foobar/dev/KEY2", "/psenv/foobar/dev/KEY3"}, rollback.Restored)
+
+	e.NewClient = newClient
+	results = e.Get(ctx, []string{"dev"}, GetOptions{Decrypt: true, WithMetadata: true})
+	require.NoError(t, results.Err())
+	params := results.Params()
+	require.Equal(t, "value1", params["/psenv/foobar/dev/KEY1"].Value)
+	require.Equal(t, "value2", params["/psenv/foobar/dev/KEY2"].Value)
+	require.Equal(t, types.ParameterTierAdvanced, params["/psenv/foobar/dev/KEY2"].Tier)
+	require.Equal(t, types.ParameterTierStandard, params["/psenv/foobar/dev/KEY3"].Tier)
+}
+
+func TestPutClearsRemovedTagsAndDescription(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {"/psenv/foobar/dev/KEY": {Value: "value", Description: "the key", Tags: map[string]string{"team": "platform", "tier": "gold"}}},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+
+	results = e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {"/psenv/foobar/dev/KEY": {Value: "value", Tags: map[string]string{"team": "platform"}}},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY"}, results[0].Updated)
+
+	param := results[0].Params["/psenv/foobar/dev/KEY"]
+	require.Empty(t, param.Description)
+	require.Equal(t, map[string]string{"team": "platform"}, param.Tags)
+}
+
+func TestPutRemovesPoliciesOfAdvancedParameters(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	results := e.Put(ctx, map[string]map[string]parameterstore.Parameter{
+		"dev": {"/psenv/foobar/dev/KEY": {Value: "value", Policies: parameterstore.Policies{Expiration: "2030-01-01T00:00:00.000Z"}}},
+	}, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+
+	// the parameter can't go back to the standard tier, so it stays advanced
+	local := map[string]map[string]parameterstore.Parameter{
+		"dev": {"/psenv/foobar/dev/KEY": {Value: "value"}},
+	}
+	results = e.Put(ctx, local, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY"}, results[0].Updated)
+	param := results[0].Params["/psenv/foobar/dev/KEY"]
+	require.True(t, param.Policies.IsEmpty())
+	require.Equal(t, types.ParameterTierAdvanced, param.Tier)
+
+	results = e.Put(ctx, local, PutOptions{Overwrite: true})
+	require.NoError(t, results.Err())
+	require.Empty(t, results[0].Updated)
+}
@@ -0,0 +1,324 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/pytoolbelt/psenv/internal/utils"
+)
+
+// DefaultVerifyTimeout is how long a put waits for its writes to become visible
+const DefaultVerifyTimeout = 30 * time.Second
+
+// delays between reads while verifying writes
+const (
+	verifyInitialDelay = 100 * time.Millisecond
+	verifyMaxDelay     = 2 * time.Second
+)
+
+// PutOptions control how local parameters are written
+type PutOptions struct {
+	// KeyID returns the KMS key that encrypts the secure strings of an environment
+	KeyID func(env string) string
+
+	Overwrite bool
+
+	// VerifyTimeout bounds how long to wait to read back what was written.
+	// Zero uses DefaultVerifyTimeout.
+	VerifyTimeout time.Duration
+
+	// ConfirmDelete, when set, is asked before anything is written to an environment
+	// that has parameters to delete. An error leaves the environment untouched. It is
+	// never called for two environments at the same time, so it can prompt.
+	ConfirmDelete func(env string, names []string) error
+
+	// Trash, when set, is handed the parameters of an environment that are about to be
+	// deleted, as read with their values and metadata, before anything is written to
+	// it. An error leaves the environment untouched. The untrash func it returns, if
+	// any, removes the copies again once a rollback has put them back.
+	Trash func(ctx context.Context, ps *parameterstore.ParameterStore, env, path string, params map[string]parameterstore.Parameter) (untrash func(context.Context) error, err error)
+
+	// Rollback stops at the first write to an environment that fails and puts the
+	// environment back the way it was read before the put, see Result.Rollback
+	Rollback bool
+}
+
+// Rollback reports how a failed put of an environment was undone
+type Rollback struct {
+	// Removed lists the parameters the put added that were deleted again
+	Removed []string
+
+	// Restored lists the parameters put back to their previous value and metadata.
+	// They keep their value but get a new version, versions can't be rolled back.
+	Restored []string
+
+	// Failed lists the parameters that could not be rolled back and may be left as the
+	// failed put wrote them. Err says why.
+	Failed []string
+	Err    error
+}
+
+// Put makes the parameters of every environment match the local ones. New and changed
+// parameters are put, parameters that only exist remotely are deleted, and the
+// environment is then read back until every write is visible at its new version.
+func (e *Engine) Put(ctx context.Context, local map[string]map[string]parameterstore.Parameter, opts PutOptions) Results {
+	var confirmMu sync.Mutex
+
+	return e.run(ctx, sortedEnvs(local), func(ctx context.Context, ps *parameterstore.ParameterStore, result *Result) error {
+		path := result.Path
+
+		// compare against decrypted values, otherwise every secure string looks changed
+		remote, err := ps.GetParametersWithMetadata(ctx, path, true)
+		if err != nil {
+			return err
+		}
+
+		keyID := ""
+		if opts.KeyID != nil {
+			keyID = opts.KeyID(result.Env)
+		}
+
+		changes := utils.MergeLocalAndRemoteParams(local[result.Env], remote)
+		if len(changes.ToDelete) > 0 && opts.ConfirmDelete != nil {
+			sort.Strings(changes.ToDelete)
+			confirmMu.Lock()
+			err := opts.ConfirmDelete(result.Env, changes.ToDelete)
+			confirmMu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+
+		var untrash func(context.Context) error
+		if len(changes.ToDelete) > 0 && opts.Trash != nil {
+			deleting := make(map[string]parameterstore.Parameter, len(changes.ToDelete))
+			for _, name := range changes.ToDelete {
+				deleting[name] = remote[name]
+			}
+			untrash, err = opts.Trash(ctx, ps, result.Env, path, deleting)
+			if err != nil {
+				return err
+			}
+		}
+		written := make(map[string]int64)
+		var errs []error
+
+		// the parameters a failed write may have changed without reporting it
+		var maybeAdded, maybeChanged []string
+		stopped := func() bool {
+			return opts.Rollback && errors.Join(errs...) != nil
+		}
+
+		if len(changes.ToAdd) > 0 {
+			versions, failed, err := putSorted(ctx, ps, changes.ToAdd, keyID, opts.Overwrite)
+			result.Added = recordVersions(written, versions)
+			maybeAdded = append(maybeAdded, failed...)
+			errs = append(errs, err)
+		}
+
+		if len(changes.ToUpdate) > 0 && !stopped() {
+			versions, failed, err := putSorted(ctx, ps, changes.ToUpdate, keyID, opts.Overwrite)
+			result.Updated = recordVersions(written, versions)
+			maybeChanged = append(maybeChanged, failed...)
+			errs = append(errs, err)
+		}
+
+		if len(changes.ToDelete) > 0 && !stopped() {
+			err := ps.DeleteParameters(ctx, changes.ToDelete)
+			result.Deleted = deletedNames(changes.ToDelete, err)
+			var deleteErr *parameterstore.DeleteParametersError
+			if errors.As(err, &deleteErr) {
+				// a batch that errored may still have been deleted
+				maybeChanged = append(maybeChanged, deleteErr.Failed...)
+			}
+			errs = append(errs, err)
+		}
+
+		e.printf("%s: %d added, %d updated, %d deleted\n", result.Env, len(result.Added), len(result.Updated), len(result.Deleted))
+
+		if err := errors.Join(errs...); err != nil {
+			if opts.Rollback {
+				added := append(slices.Clone(result.Added), maybeAdded...)
+				changed := append(append(slices.Clone(result.Updated), result.Deleted...), maybeChanged...)
+				result.Rollback = rollback(ctx, ps, remote, added, changed, keyID)
+				e.printRollback(result.Env, result.Rollback)
+
+				// nothing was deleted in the end, so the trash copies would only be orphans
+				if result.Rollback.Err == nil && untrash != nil {
+					if err := untrash(context.WithoutCancel(ctx)); err != nil {
+						e.printf("%s: the trash copies of the rolled back deletes were kept, %s\n", result.Env, err)
+					}
+				}
+			}
+			return err
+		}
+
+		timeout := opts.VerifyTimeout
+		if timeout <= 0 {
+			timeout = DefaultVerifyTimeout
+		}
+		result.Params, err = verify(ctx, ps, path, written, result.Deleted, timeout)
+		return err
+	})
+}
+
+// putSorted puts parameters one at a time in name order and stops at the first that
+// fails. Besides the versions written it returns the name that failed, which may
+// have been written even so, e.g. when only tagging it failed.
+func putSorted(ctx context.Context, ps *parameterstore.ParameterStore, params map[string]parameterstore.Parameter, keyID string, overwrite bool) (map[string]int64, []string, error) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	written := make(map[string]int64, len(params))
+	for _, name := range names {
+		versions, err := ps.PutParameters(ctx, map[string]parameterstore.Parameter{name: params[name]}, keyID, overwrite)
+		if err != nil {
+			return written, []string{name}, err
+		}
+		written[name] = versions[name]
+	}
+	return written, nil, nil
+}
+
+// rollback undoes a failed put of an environment. Parameters it added are deleted and
+// the ones it updated or deleted are put back from the snapshot read before the put,
+// with their KMS key, tier and tags. It carries on when the put was cancelled, a half
+// rolled back environment helps nobody.
+func rollback(ctx context.Context, ps *parameterstore.ParameterStore, snapshot map[string]parameterstore.Parameter, added, changed []string, keyID string) *Rollback {
+	ctx = context.WithoutCancel(ctx)
+	report := &Rollback{}
+	var errs []error
+
+	if len(added) > 0 {
+		sort.Strings(added)
+		err := ps.DeleteParameters(ctx, added)
+		var deleteErr *parameterstore.DeleteParametersError
+		switch {
+		case err == nil:
+			report.Removed = added
+		case errors.As(err, &deleteErr) && len(deleteErr.Failed) == 0:
+			// invalid parameters were never written, so there is nothing to remove
+			report.Removed = deleteErr.Deleted
+		case errors.As(err, &deleteErr):
+			report.Removed = deleteErr.Deleted
+			report.Failed = append(report.Failed, deleteErr.Failed...)
+			errs = append(errs, err)
+		default:
+			report.Failed = append(report.Failed, added...)
+			errs = append(errs, err)
+		}
+	}
+
+	sort.Strings(changed)
+	for _, name := range slices.Compact(changed) {
+		param := snapshot[name]
+		param.Version = 0
+
+		// a put that moved a standard parameter to the advanced tier can't be undone,
+		// intelligent tiering puts it back in the standard tier wherever that is possible
+		if param.Tier != types.ParameterTierAdvanced {
+			param.Tier = types.ParameterTierIntelligentTiering
+		}
+		paramKeyID := param.KeyID
+		if paramKeyID == "" {
+			paramKeyID = keyID
+		}
+		if _, err := ps.PutParameters(ctx, map[string]parameterstore.Parameter{name: param}, paramKeyID, true); err != nil {
+			report.Failed = append(report.Failed, name)
+			errs = append(errs, err)
+			continue
+		}
+		report.Restored = append(report.Restored, name)
+	}
+
+	sort.Strings(report.Failed)
+	report.Err = errors.Join(errs...)
+	return report
+}
+
+// printRollback reports the state a rolled back environment was left in
+func (e *Engine) printRollback(env string, report *Rollback) {
+	if report.Err == nil {
+		e.printf("%s: rolled back, %d removed, %d restored, the environment is as it was before the put\n", env, len(report.Removed), len(report.Restored))
+		return
+	}
+	e.printf("%s: rollback failed, %d removed, %d restored, these may be left as the put wrote them: %s\n", env, len(report.Removed), len(report.Restored), strings.Join(report.Failed, ", "))
+}
+
+// recordVersions adds the versions to written and returns the sorted names
+func recordVersions(written, versions map[string]int64) []string {
+	names := make([]string, 0, len(versions))
+	for name, version := range versions {
+		written[name] = version
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deletedNames returns the names a delete removed. A partial failure reports
+// exactly which ones were deleted.
+func deletedNames(names []string, err error) []string {
+	if err == nil {
+		return names
+	}
+	var deleteErr *parameterstore.DeleteParametersError
+	if errors.As(err, &deleteErr) {
+		return deleteErr.Deleted
+	}
+	return nil
+}
+
+// verify reads an environment back until every written parameter is visible at the
+// version it was written as and every deleted parameter is gone
+func verify(ctx context.Context, ps *parameterstore.ParameterStore, path string, written map[string]int64, deleted []string, timeout time.Duration) (map[string]parameterstore.Parameter, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := verifyInitialDelay
+	for {
+		params, err := ps.GetParametersWithMetadata(ctx, path, true)
+		if err == nil {
+			pending := unverified(params, written, deleted)
+			if len(pending) == 0 {
+				return params, nil
+			}
+			err = fmt.Errorf("writes not yet visible: %s", strings.Join(pending, ", "))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error verifying writes to %s: %w", path, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, verifyMaxDelay)
+	}
+}
+
+// unverified returns the names that are not yet visible as they were written
+func unverified(params map[string]parameterstore.Parameter, written map[string]int64, deleted []string) []string {
+	var pending []string
+	for name, version := range written {
+		if params[name].Version < version {
+			pending = append(pending, name)
+		}
+	}
+	for _, name := range deleted {
+		if _, ok := params[name]; ok {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
@@ -0,0 +1,67 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+)
+
+func init() {
+	Register("chamber", newChamber)
+}
+
+// chamber imports chamber services, which keep their keys directly below the service
+// at /<service>/<key>. Sources maps every environment to its service, and Source, when
+// set, is the path the services live under.
+type chamber struct {
+	opts Options
+}
+
+func newChamber(opts Options) (Importer, error) {
+	if len(opts.Sources) == 0 {
+		return nil, fmt.Errorf("chamber needs the service of every environment, e.g. dev=myapp-dev")
+	}
+	if opts.Store == nil {
+		return nil, fmt.Errorf("chamber needs a parameter store to read the services from")
+	}
+	return &chamber{opts: opts}, nil
+}
+
+func (c *chamber) Import(ctx context.Context) (Environments, error) {
+	envs := make([]string, 0, len(c.opts.Sources))
+	for env := range c.opts.Sources {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	imported := make(Environments)
+	for _, env := range envs {
+		service := path.Join("/", c.opts.Source, strings.Trim(c.opts.Sources[env], "/"))
+
+		params, err := c.opts.Store.GetParametersWithMetadata(ctx, service, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", env, err)
+		}
+
+		secrets := make(config.Secrets)
+		for name, param := range params {
+			key, ok := config.RelativeKey(service, name)
+			if !ok {
+				continue
+			}
+
+			// chamber lower cases keys, psenv upper cases them when they are put
+			key = config.SecretKey(key)
+			if _, ok := secrets[key]; ok {
+				return nil, fmt.Errorf("%s: service %s has more than one key %s", env, service, key)
+			}
+			secrets[key] = config.NewSecretFromParameter(param)
+		}
+		imported[env] = secrets
+	}
+	return imported, nil
+}
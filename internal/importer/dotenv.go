@@ -0,0 +1,185 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+)
+
+func init() {
+	Register("dotenv", newDotenv)
+}
+
+// dotenv imports a dotenv file per environment. Unless Sources names the file of every
+// environment, the Source directory is searched for .env (base), .env.<env> and
+// <env>.env files, which is also how Doppler and similar tools download their configs.
+type dotenv struct {
+	files map[string]string
+}
+
+func newDotenv(opts Options) (Importer, error) {
+	if len(opts.Sources) > 0 {
+		return &dotenv{files: opts.Sources}, nil
+	}
+
+	dir := opts.Source
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		env, ok := dotenvEnvironment(entry.Name())
+		if !ok {
+			continue
+		}
+		if other, ok := files[env]; ok {
+			return nil, fmt.Errorf("both %s and %s are files of environment %s", other, entry.Name(), env)
+		}
+		files[env] = filepath.Join(dir, entry.Name())
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no dotenv files found in %s", dir)
+	}
+	return &dotenv{files: files}, nil
+}
+
+// dotenvEnvironment returns the environment of a dotenv file name
+func dotenvEnvironment(name string) (string, bool) {
+	switch {
+	case name == ".env":
+		return "base", true
+	case strings.HasPrefix(name, ".env.") && len(name) > len(".env."):
+		env := strings.TrimPrefix(name, ".env.")
+		// .env.example and friends are templates, not environments
+		if env == "example" || env == "sample" || env == "template" {
+			return "", false
+		}
+		return env, true
+	case strings.HasSuffix(name, ".env") && len(name) > len(".env"):
+		return strings.TrimSuffix(name, ".env"), true
+	}
+	return "", false
+}
+
+func (d *dotenv) Import(ctx context.Context) (Environments, error) {
+	envs := make([]string, 0, len(d.files))
+	for env := range d.files {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	imported := make(Environments)
+	for _, env := range envs {
+		values, err := ReadDotenv(d.files[env])
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		// psenv upper cases keys when they are put, so foo and FOO would overwrite each other
+		secrets := make(config.Secrets)
+		from := make(map[string]string, len(keys))
+		var collisions []string
+		for _, key := range keys {
+			secretKey := config.SecretKey(key)
+			if other, ok := from[secretKey]; ok {
+				collisions = append(collisions, fmt.Sprintf("%s and %s are both key %s", other, key, secretKey))
+				continue
+			}
+			from[secretKey] = key
+			secrets[secretKey] = config.Secret{Value: values[key]}
+		}
+		if len(collisions) > 0 {
+			return nil, fmt.Errorf("%s: %s has keys that can't be told apart once imported: %s", env, d.files[env], strings.Join(collisions, "; "))
+		}
+		imported[env] = secrets
+	}
+	return imported, nil
+}
+
+// ReadDotenv reads the variables of a dotenv file. Lines may start with export, values
+// may be single quoted (as is) or double quoted (with \n, \t, \" and \\ escapes), and
+// unquoted values end at a # comment.
+func ReadDotenv(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", file, number)
+		}
+
+		value, err := dotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, number, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func dotenvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quoted value")
+		}
+		return value[1 : end+1], nil
+
+	case strings.HasPrefix(value, `"`):
+		// find the closing quote that isn't escaped
+		for end := 1; end < len(value); end++ {
+			switch value[end] {
+			case '\\':
+				end++
+			case '"':
+				return strconv.Unquote(value[:end+1])
+			}
+		}
+		return "", fmt.Errorf("unterminated double quoted value")
+	}
+
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = value[:comment]
+	}
+	return strings.TrimSpace(value), nil
+}
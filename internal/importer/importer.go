@@ -0,0 +1,189 @@
+// Package importer turns the layouts of other tools into psenv environments. Every
+// layout is a small importer behind the Importer interface, registered by name so the
+// import command can pick it. Imports always go through a Plan, which shows what
+// would change in the parameter store before anything is written.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/pytoolbelt/psenv/internal/utils"
+)
+
+// Environments holds imported secrets by environment and key
+type Environments map[string]config.Secrets
+
+// Importer reads the environments of another tool
+type Importer interface {
+	Import(ctx context.Context) (Environments, error)
+}
+
+// Options are passed to every importer, each uses the ones that apply to it
+type Options struct {
+	// Source is where to import from, e.g. a directory of dotenv files
+	Source string
+
+	// Sources maps psenv environments to where each is imported from, e.g. dev to
+	// the chamber service myapp-dev
+	Sources map[string]string
+
+	// Store reads from the parameter store for importers that need it
+	Store *parameterstore.ParameterStore
+}
+
+// Factory creates an importer
+type Factory func(opts Options) (Importer, error)
+
+var factories = make(map[string]Factory)
+
+// Register makes an importer available by name. It panics when the name is taken.
+func Register(name string, factory Factory) {
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("importer %s is already registered", name))
+	}
+	factories[name] = factory
+}
+
+// New creates the importer registered with name
+func New(name string, opts Options) (Importer, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown importer %s, must be one of %s", name, strings.Join(Names(), ", "))
+	}
+	return factory(opts)
+}
+
+// Names returns the names of every registered importer, sorted
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EnvironmentPlan is what importing does to one environment. Parameters that only
+// exist in the parameter store are kept, an import never deletes.
+type EnvironmentPlan struct {
+	Env       string
+	Path      string
+	Add       map[string]parameterstore.Parameter
+	Update    map[string]parameterstore.Parameter
+	Unchanged []string
+}
+
+// Plan is what importing does to every environment
+type Plan []EnvironmentPlan
+
+// NewPlan compares the imported environments with what is in the parameter store.
+// The secrets config decides where each environment lives and how its secrets
+// become parameters, remote returns the parameters that are there already.
+func NewPlan(ctx context.Context, imported Environments, secretsConfig *config.SecretsConfig, remote func(ctx context.Context, env string) (map[string]parameterstore.Parameter, error)) (Plan, error) {
+	envs := make([]string, 0, len(imported))
+	for env := range imported {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	plan := make(Plan, 0, len(envs))
+	for _, env := range envs {
+		target := &config.SecretsConfig{
+			Project:      secretsConfig.Project,
+			Prefix:       secretsConfig.Prefix,
+			PathTemplate: secretsConfig.PathTemplate,
+			PathVars:     secretsConfig.PathVars,
+			Environments: map[string]config.Secrets{env: imported[env]},
+		}
+		local := target.GetEnvironmentParams(env)
+
+		existing, err := remote(ctx, env)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", env, err)
+		}
+
+		changes := utils.MergeLocalAndRemoteParams(local, existing)
+		envPlan := EnvironmentPlan{
+			Env:    env,
+			Path:   target.GetEnvironmentPath(env),
+			Add:    changes.ToAdd,
+			Update: changes.ToUpdate,
+		}
+		for name := range local {
+			_, added := changes.ToAdd[name]
+			_, updated := changes.ToUpdate[name]
+			if !added && !updated {
+				envPlan.Unchanged = append(envPlan.Unchanged, name)
+			}
+		}
+		sort.Strings(envPlan.Unchanged)
+		plan = append(plan, envPlan)
+	}
+	return plan, nil
+}
+
+// IsEmpty reports whether the plan changes nothing
+func (p Plan) IsEmpty() bool {
+	for _, env := range p {
+		if len(env.Add) > 0 || len(env.Update) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes every change of the plan
+func (p Plan) Print(w io.Writer) {
+	for _, env := range p {
+		fmt.Fprintf(w, "%s (%s): %d to add, %d to update, %d unchanged\n", env.Env, env.Path, len(env.Add), len(env.Update), len(env.Unchanged))
+		for _, name := range sortedNames(env.Add) {
+			fmt.Fprintf(w, "  + %s\n", name)
+		}
+		for _, name := range sortedNames(env.Update) {
+			fmt.Fprintf(w, "  ~ %s\n", name)
+		}
+	}
+}
+
+// Apply writes the plan to the parameter store of every environment. SecureStrings
+// of an environment are encrypted with the key keyID returns for it.
+func (p Plan) Apply(ctx context.Context, store func(env string) (*parameterstore.ParameterStore, error), keyID func(env string) string) error {
+	for _, env := range p {
+		if len(env.Add) == 0 && len(env.Update) == 0 {
+			continue
+		}
+
+		ps, err := store(env.Env)
+		if err != nil {
+			return fmt.Errorf("%s: %w", env.Env, err)
+		}
+
+		params := make(map[string]parameterstore.Parameter, len(env.Add)+len(env.Update))
+		for name, param := range env.Add {
+			params[name] = param
+		}
+		for name, param := range env.Update {
+			params[name] = param
+		}
+
+		if _, err := ps.PutParameters(ctx, params, keyID(env.Env), true); err != nil {
+			return fmt.Errorf("%s: %w", env.Env, err)
+		}
+	}
+	return nil
+}
+
+func sortedNames(params map[string]parameterstore.Parameter) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/devserver/devservertest"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestNames(t *testing.T) {
+	require.Equal(t, []string{"chamber", "dotenv"}, Names())
+
+	_, err := New("vault", Options{})
+	require.ErrorContains(t, err, "must be one of chamber, dotenv")
+}
+
+func TestReadDotenv(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".env", `
+# database
+export DB_HOST=localhost
+DB_PORT = 5432 # the default port
+GREETING="hello\nworld"
+LITERAL='no \n escapes # here'
+EMPTY=
+URL=https://example.com/#anchor
+`)
+
+	values, err := ReadDotenv(filepath.Join(dir, ".env"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"DB_HOST":  "localhost",
+		"DB_PORT":  "5432",
+		"GREETING": "hello\nworld",
+		"LITERAL":  `no \n escapes # here`,
+		"EMPTY":    "",
+		"URL":      "https://example.com/#anchor",
+	}, values)
+
+	writeFile(t, dir, "broken.env", "JUST_A_NAME\n")
+	_, err = ReadDotenv(filepath.Join(dir, "broken.env"))
+	require.ErrorContains(t, err, "broken.env:1")
+}
+
+func TestDotenvFindsEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".env", "LOG_LEVEL=info\n")
+	writeFile(t, dir, ".env.dev", "DB_HOST=dev-db\n")
+	writeFile(t, dir, "prod.env", "DB_HOST=prod-db\n")
+	writeFile(t, dir, ".env.example", "DB_HOST=\n")
+
+	imp, err := New("dotenv", Options{Source: dir})
+	require.NoError(t, err)
+
+	imported, err := imp.Import(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, Environments{
+		"base": {"LOG_LEVEL": {Value: "info"}},
+		"dev":  {"DB_HOST": {Value: "dev-db"}},
+		"prod": {"DB_HOST": {Value: "prod-db"}},
+	}, imported)
+
+	writeFile(t, dir, "dev.env", "DB_HOST=other\n")
+	_, err = New("dotenv", Options{Source: dir})
+	require.ErrorContains(t, err, "environment dev")
+}
+
+func TestDotenvRejectsKeysThatOnlyDifferInCase(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".env.dev", "db_host=lower\nDB_HOST=upper\nlog_level=debug\n")
+
+	imp, err := New("dotenv", Options{Sources: map[string]string{"dev": filepath.Join(dir, ".env.dev")}})
+	require.NoError(t, err)
+	_, err = imp.Import(context.Background())
+	require.ErrorContains(t, err, "DB_HOST and db_host are both key DB_HOST")
+	require.NotContains(t, err.Error(), "LOG_LEVEL")
+
+	writeFile(t, dir, ".env.dev", "db_host=lower\nlog_level=debug\n")
+	imported, err := imp.Import(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, Environments{"dev": {"DB_HOST": {Value: "lower"}, "LOG_LEVEL": {Value: "debug"}}}, imported)
+}
+
+func TestChamberImportsServices(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+	ctx := context.Background()
+
+	_, err := ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		"/myapp-dev/db_password":  {Value: "dev-secret"},
+		"/myapp-dev/log_level":    {Value: "debug", Type: "String"},
+		"/myapp-prod/db_password": {Value: "prod-secret"},
+		"/other/db_password":      {Value: "other"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	_, err = New("chamber", Options{Store: ps})
+	require.Error(t, err)
+
+	imp, err := New("chamber", Options{Store: ps, Sources: map[string]string{"dev": "myapp-dev", "prod": "myapp-prod"}})
+	require.NoError(t, err)
+
+	imported, err := imp.Import(ctx)
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+	require.Equal(t, "dev-secret", imported["dev"]["DB_PASSWORD"].Value)
+	require.Equal(t, config.Secret{Value: "debug", Type: "String"}, imported["dev"]["LOG_LEVEL"])
+	require.Equal(t, "prod-secret", imported["prod"]["DB_PASSWORD"].Value)
+}
+
+func TestPlanAddsAndUpdatesWithoutDeleting(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+	ctx := context.Background()
+
+	_, err := ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEPT":      {Value: "kept", Type: "String"},
+		"/psenv/foobar/dev/CHANGED":   {Value: "old", Type: "String"},
+		"/psenv/foobar/dev/UNCHANGED": {Value: "same", Type: "String"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	target := &config.SecretsConfig{Prefix: "/psenv", Project: "foobar"}
+	remote := func(ctx context.Context, env string) (map[string]parameterstore.Parameter, error) {
+		return ps.GetParametersWithMetadata(ctx, target.GetEnvironmentPath(env), true)
+	}
+
+	imported := Environments{"dev": {
+		"CHANGED":   {Value: "new", Type: "String"},
+		"UNCHANGED": {Value: "same", Type: "String"},
+		"ADDED":     {Value: "added", Type: "String"},
+	}}
+
+	plan, err := NewPlan(ctx, imported, target, remote)
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+	require.Contains(t, plan[0].Add, "/psenv/foobar/dev/ADDED")
+	require.Contains(t, plan[0].Update, "/psenv/foobar/dev/CHANGED")
+	require.False(t, plan.IsEmpty())
+
+	store := func(env string) (*parameterstore.ParameterStore, error) { return ps, nil }
+	keyID := func(env string) string { return "alias/aws/ssm" }
+	require.NoError(t, plan.Apply(ctx, store, keyID))
+
+	params, err := ps.GetParameters(ctx, "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"/psenv/foobar/dev/ADDED":     "added",
+		"/psenv/foobar/dev/CHANGED":   "new",
+		"/psenv/foobar/dev/KEPT":      "kept",
+		"/psenv/foobar/dev/UNCHANGED": "same",
+	}, parameterstore.Values(params))
+
+	plan, err = NewPlan(ctx, imported, target, remote)
+	require.NoError(t, err)
+	require.True(t, plan.IsEmpty())
+}
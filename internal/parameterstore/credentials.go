@@ -0,0 +1,145 @@
+package parameterstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// DefaultSessionName is the session name used when assuming a role without one configured
+const DefaultSessionName = "psenv"
+
+// DefaultEndpointRegion is the region used with a custom endpoint when none is configured.
+// Emulators accept any region, but the SDK refuses to sign a request without one.
+const DefaultEndpointRegion = "us-east-1"
+
+// configKey identifies a loaded AWS config by the identity it signs requests as. The
+// region and endpoint are set per client, so environments that only differ in region
+// share their credentials and an MFA code is prompted for once. The MFA token provider
+// is a function and can't be compared, and of the secret access key only a hash is kept.
+type configKey struct {
+	Profile     string
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+	MFASerial   string
+	AccessKeyID string
+	SecretHash  [sha256.Size]byte
+	Retryer     retryerKey
+}
+
+var configCache = struct {
+	sync.Mutex
+	configs map[configKey]aws.Config
+}{configs: make(map[configKey]aws.Config)}
+
+// promptMutex serialises MFA prompts. Environments in different accounts each have
+// their own credentials, and their prompts must not interleave on the terminal.
+var promptMutex sync.Mutex
+
+func newConfigKey(o Options) configKey {
+	return configKey{
+		Profile:     o.Profile,
+		RoleARN:     o.RoleARN,
+		ExternalID:  o.ExternalID,
+		SessionName: o.SessionName,
+		MFASerial:   o.MFASerial,
+		AccessKeyID: o.AccessKeyID,
+		SecretHash:  sha256.Sum256([]byte(o.SecretAccessKey)),
+		Retryer:     newRetryerKey(o),
+	}
+}
+
+// loadConfig loads the AWS config for the options, reusing a previously loaded
+// config and its credentials cache when the identity is the same. A role is assumed
+// in the region of the first client that loads the config.
+func loadConfig(options Options) (aws.Config, error) {
+	key := newConfigKey(options)
+
+	configCache.Lock()
+	defer configCache.Unlock()
+
+	if cfg, ok := configCache.configs[key]; ok {
+		return cfg, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tokenProvider := syncTokenProvider(options.MFATokenProvider)
+
+	retryer := sharedRetryer(options)
+
+	loadOpts := []func(*config.LoadOptions) error{
+		// profiles that assume a role with MFA prompt through the same synchronised provider
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = tokenProvider
+		}),
+		config.WithRetryer(func() aws.Retryer { return retryer }),
+	}
+	if options.Profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(options.Profile))
+	}
+	if options.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(options.Region))
+	}
+	if options.AccessKeyID != "" {
+		provider := credentials.NewStaticCredentialsProvider(options.AccessKeyID, options.SecretAccessKey, "")
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(provider))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return cfg, err
+	}
+
+	if options.RoleARN != "" {
+		cfg.Credentials = aws.NewCredentialsCache(newAssumeRoleProvider(cfg, options, tokenProvider))
+	}
+
+	configCache.configs[key] = cfg
+	return cfg, nil
+}
+
+func newAssumeRoleProvider(cfg aws.Config, options Options, tokenProvider func() (string, error)) *stscreds.AssumeRoleProvider {
+	return stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), options.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = DefaultSessionName
+		if options.SessionName != "" {
+			o.RoleSessionName = options.SessionName
+		}
+		if options.ExternalID != "" {
+			o.ExternalID = aws.String(options.ExternalID)
+		}
+		if options.MFASerial != "" {
+			o.SerialNumber = aws.String(options.MFASerial)
+			o.TokenProvider = tokenProvider
+		}
+	})
+}
+
+// syncTokenProvider wraps a token provider so only one MFA prompt runs at a time.
+// Without a provider the user is prompted on stdin.
+func syncTokenProvider(provider func() (string, error)) func() (string, error) {
+	if provider == nil {
+		provider = stscreds.StdinTokenProvider
+	}
+	return func() (string, error) {
+		promptMutex.Lock()
+		defer promptMutex.Unlock()
+		return provider()
+	}
+}
+
+// ClearConfigCache forgets all loaded AWS configs and their cached credentials
+func ClearConfigCache() {
+	configCache.Lock()
+	defer configCache.Unlock()
+	configCache.configs = make(map[configKey]aws.Config)
+}
@@ -0,0 +1,131 @@
+package parameterstore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigIsSharedBetweenClients(t *testing.T) {
+	ClearConfigCache()
+	defer ClearConfigCache()
+
+	options := Options{Region: "eu-west-1", RoleARN: "arn:aws:iam::123456789012:role/psenv"}
+
+	first, err := loadConfig(options)
+	require.NoError(t, err)
+
+	second, err := loadConfig(options)
+	require.NoError(t, err)
+	require.Same(t, first.Credentials, second.Credentials)
+
+	other, err := loadConfig(Options{Region: "eu-west-1", RoleARN: "arn:aws:iam::210987654321:role/psenv"})
+	require.NoError(t, err)
+	require.NotSame(t, first.Credentials, other.Credentials)
+}
+
+func TestRegionsShareTheCredentialsOfAnIdentity(t *testing.T) {
+	ClearConfigCache()
+	defer ClearConfigCache()
+
+	identity := []Option{
+		WithRoleARN("arn:aws:iam::123456789012:role/psenv"),
+		WithMFA("arn:aws:iam::123456789012:mfa/user"),
+	}
+	first, err := loadConfig(Options{Region: "eu-west-1", RoleARN: "arn:aws:iam::123456789012:role/psenv", MFASerial: "arn:aws:iam::123456789012:mfa/user"})
+	require.NoError(t, err)
+	second, err := loadConfig(Options{Region: "us-east-1", RoleARN: "arn:aws:iam::123456789012:role/psenv", MFASerial: "arn:aws:iam::123456789012:mfa/user"})
+	require.NoError(t, err)
+	require.Same(t, first.Credentials, second.Credentials)
+
+	// while every client talks to its own region
+	ps, err := New(append(identity, WithRegion("us-east-1"))...)
+	require.NoError(t, err)
+	require.Equal(t, "us-east-1", ps.Client.(*ssm.Client).Options().Region)
+	ps, err = New(append(identity, WithRegion("ap-southeast-2"))...)
+	require.NoError(t, err)
+	require.Equal(t, "ap-southeast-2", ps.Client.(*ssm.Client).Options().Region)
+}
+
+func TestNewAcceptsAssumeRoleOptions(t *testing.T) {
+	ClearConfigCache()
+	defer ClearConfigCache()
+
+	ps, err := New(
+		WithRegion("eu-west-1"),
+		WithRoleARN("arn:aws:iam::123456789012:role/psenv"),
+		WithExternalID("external"),
+		WithSessionName("session"),
+		WithMFA("arn:aws:iam::123456789012:mfa/user"),
+		WithMFATokenProvider(func() (string, error) { return "123456", nil }),
+	)
+	require.NoError(t, err)
+	require.NotNil(t, ps.Client)
+}
+
+func TestSyncTokenProviderSerialisesPrompts(t *testing.T) {
+	var running, maxRunning int32
+	provider := syncTokenProvider(func() (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		atomic.AddInt32(&running, -1)
+		return "123456", nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := provider()
+			require.NoError(t, err)
+			require.Equal(t, "123456", token)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), maxRunning)
+}
+
+func TestLoadConfigWithCustomEndpoint(t *testing.T) {
+	ClearConfigCache()
+	defer ClearConfigCache()
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+
+	cfg, err := loadConfig(Options{EndpointURL: "http://localhost:4566", AccessKeyID: "test", SecretAccessKey: "test"})
+	require.NoError(t, err)
+
+	// the endpoint is only used by the ssm client, sts keeps talking to AWS
+	require.Nil(t, cfg.BaseEndpoint)
+	ps, err := New(WithEndpointURL("http://localhost:4566"), WithStaticCredentials("test", "test"))
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:4566", aws.ToString(ps.Client.(*ssm.Client).Options().BaseEndpoint))
+	require.Equal(t, DefaultEndpointRegion, ps.Client.(*ssm.Client).Options().Region)
+
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "test", creds.AccessKeyID)
+}
+
+func TestLoadConfigKeysOnTheSecretAccessKey(t *testing.T) {
+	ClearConfigCache()
+	defer ClearConfigCache()
+
+	first, err := loadConfig(Options{Region: "us-east-1", AccessKeyID: "key", SecretAccessKey: "one"})
+	require.NoError(t, err)
+	second, err := loadConfig(Options{Region: "us-east-1", AccessKeyID: "key", SecretAccessKey: "two"})
+	require.NoError(t, err)
+
+	creds, err := second.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "two", creds.SecretAccessKey)
+	require.NotSame(t, first.Credentials, second.Credentials)
+}
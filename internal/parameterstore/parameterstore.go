@@ -2,11 +2,14 @@ package parameterstore
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 )
@@ -16,46 +19,336 @@ type SSMClient interface {
 	PutParameter(ctx context.Context, input *ssm.PutParameterInput, opts ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
 	GetParametersByPath(ctx context.Context, input *ssm.GetParametersByPathInput, opts ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
 	DeleteParameters(ctx context.Context, input *ssm.DeleteParametersInput, opts ...func(*ssm.Options)) (*ssm.DeleteParametersOutput, error)
+	AddTagsToResource(ctx context.Context, input *ssm.AddTagsToResourceInput, opts ...func(*ssm.Options)) (*ssm.AddTagsToResourceOutput, error)
+	ListTagsForResource(ctx context.Context, input *ssm.ListTagsForResourceInput, opts ...func(*ssm.Options)) (*ssm.ListTagsForResourceOutput, error)
+	RemoveTagsFromResource(ctx context.Context, input *ssm.RemoveTagsFromResourceInput, opts ...func(*ssm.Options)) (*ssm.RemoveTagsFromResourceOutput, error)
 }
 
+// MaxDeleteBatchSize is the most parameters the parameter store deletes in one call
+const MaxDeleteBatchSize = 10
+
+// tag keys that psenv manages on every parameter it puts
+const (
+	OwnerTagKey       = "owner"
+	ProjectTagKey     = "psenv:project"
+	EnvironmentTagKey = "psenv:env"
+)
+
+// DefaultOperationTimeout is the deadline of a single request to the parameter store,
+// including its retries, when no operation timeout is given
+const DefaultOperationTimeout = 30 * time.Second
+
 type ParameterStore struct {
 	Client SSMClient
+
+	// OperationTimeout is the deadline of each request to the parameter store. Zero
+	// leaves requests bounded only by the context passed to the method.
+	OperationTimeout time.Duration
+}
+
+// operationContext derives the context for a single request from the caller context
+func (p *ParameterStore) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.OperationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.OperationTimeout)
 }
 
-func New() (*ParameterStore, error) {
+// Parameter is a single parameter as stored in the parameter store. StringList
+// values are kept comma joined, exactly as the parameter store expects them.
+type Parameter struct {
+	Name        string
+	Value       string
+	Type        types.ParameterType
+	Version     int64
+	Description string
+	Tags        map[string]string
+	Tier        types.ParameterTier
+	Policies    Policies
+
+	// KeyID is the KMS key a secure string is encrypted with. It is only read along
+	// with the metadata, puts take the key to use as an argument.
+	KeyID string
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// EffectiveType returns the parameter type, defaulting to SecureString when none is set
+func (p Parameter) EffectiveType() types.ParameterType {
+	if p.Type == "" {
+		return types.ParameterTypeSecureString
+	}
+	return p.Type
+}
+
+// EffectiveTier returns the tier to put the parameter in. When no tier is set, values
+// that are too large for the standard tier and parameters with policies go to the advanced tier.
+func (p Parameter) EffectiveTier() types.ParameterTier {
+	if p.Tier != "" {
+		return p.Tier
+	}
+	if len(p.Value) > StandardTierMaxSize || !p.Policies.IsEmpty() {
+		return types.ParameterTierAdvanced
+	}
+	return types.ParameterTierStandard
+}
+
+// Differs reports whether the value, type or metadata of two parameters are different
+func (p Parameter) Differs(other Parameter) bool {
+	return p.Value != other.Value ||
+		p.EffectiveType() != other.EffectiveType() ||
+		p.Description != other.Description ||
+		!maps.Equal(p.Tags, other.Tags) ||
+		p.Policies != other.Policies ||
+		tiersDiffer(p.EffectiveTier(), other.EffectiveTier())
+}
+
+// tiersDiffer compares two tiers. The parameter store reports the tier that intelligent
+// tiering picked, so intelligent tiering never counts as a difference.
+func tiersDiffer(a, b types.ParameterTier) bool {
+	if a == types.ParameterTierIntelligentTiering || b == types.ParameterTierIntelligentTiering {
+		return false
+	}
+	return a != b
+}
+
+// Values returns a map of parameter names to their values
+func Values(params map[string]Parameter) map[string]string {
+	values := make(map[string]string, len(params))
+	for k, p := range params {
+		values[k] = p.Value
+	}
+	return values
+}
 
-	cfg, err := config.LoadDefaultConfig(ctx)
+// Versions returns a map of parameter names to their versions
+func Versions(params map[string]Parameter) map[string]int64 {
+	versions := make(map[string]int64, len(params))
+	for k, p := range params {
+		versions[k] = p.Version
+	}
+	return versions
+}
+
+// Options configure the AWS account and region a ParameterStore talks to
+type Options struct {
+	Profile          string
+	Region           string
+	RoleARN          string
+	ExternalID       string
+	SessionName      string
+	MFASerial        string
+	MFATokenProvider func() (string, error)
+	EndpointURL      string
+	AccessKeyID      string
+	SecretAccessKey  string
+	MaxAttempts      int
+	RetryMode        string
+	MaxBackoff       time.Duration
+	RetryObserver    func(operation string, attempts int)
+	OperationTimeout time.Duration
+}
+
+type Option func(*Options)
+
+// WithProfile uses a named profile from the shared AWS config files
+func WithProfile(profile string) Option {
+	return func(o *Options) {
+		o.Profile = profile
+	}
+}
+
+// WithRegion overrides the region from the environment or the shared config
+func WithRegion(region string) Option {
+	return func(o *Options) {
+		o.Region = region
+	}
+}
+
+// WithRoleARN assumes the given role before talking to the parameter store
+func WithRoleARN(roleARN string) Option {
+	return func(o *Options) {
+		o.RoleARN = roleARN
+	}
+}
+
+// WithExternalID sets the external id used when assuming a role
+func WithExternalID(externalID string) Option {
+	return func(o *Options) {
+		o.ExternalID = externalID
+	}
+}
+
+// WithSessionName sets the session name used when assuming a role
+func WithSessionName(sessionName string) Option {
+	return func(o *Options) {
+		o.SessionName = sessionName
+	}
+}
+
+// WithMFA assumes the role with the given MFA device. The token code is prompted for on stdin.
+func WithMFA(serial string) Option {
+	return func(o *Options) {
+		o.MFASerial = serial
+	}
+}
+
+// WithMFATokenProvider replaces the stdin prompt used to get MFA token codes
+func WithMFATokenProvider(provider func() (string, error)) Option {
+	return func(o *Options) {
+		o.MFATokenProvider = provider
+	}
+}
+
+// WithEndpointURL sends every request to a custom endpoint, such as LocalStack
+// or the psenv dev server, instead of AWS
+func WithEndpointURL(url string) Option {
+	return func(o *Options) {
+		o.EndpointURL = url
+	}
+}
+
+// WithStaticCredentials uses fixed credentials instead of the default credential chain
+func WithStaticCredentials(accessKeyID, secretAccessKey string) Option {
+	return func(o *Options) {
+		o.AccessKeyID = accessKeyID
+		o.SecretAccessKey = secretAccessKey
+	}
+}
+
+// WithOperationTimeout sets the deadline of each request to the parameter store
+func WithOperationTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.OperationTimeout = timeout
+	}
+}
+
+// New creates a ParameterStore. Clients of the same identity share their AWS config
+// and credentials, whatever their region, so a role is assumed (and an MFA token
+// prompted for) only once per command no matter how many workers create a client.
+func New(opts ...Option) (*ParameterStore, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := ValidateRetryMode(options.RetryMode); err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadConfig(options)
 	if err != nil {
 		return nil, fmt.Errorf("unable to load AWS SDK config, %v", err)
 	}
 
+	// the config is shared by every region of an identity, so the region is the client's
+	region := options.Region
+	if region == "" {
+		region = cfg.Region
+	}
+	if region == "" && options.EndpointURL != "" {
+		region = DefaultEndpointRegion
+	}
+	clientOpts := []func(*ssm.Options){func(o *ssm.Options) {
+		o.Region = region
+	}}
+
+	// only the parameter store is emulated, assuming a role still talks to AWS
+	if options.EndpointURL != "" {
+		clientOpts = append(clientOpts, func(o *ssm.Options) {
+			o.BaseEndpoint = aws.String(options.EndpointURL)
+		})
+	}
+	if options.RetryObserver != nil {
+		clientOpts = append(clientOpts, func(o *ssm.Options) {
+			o.APIOptions = append(o.APIOptions, retryObserverMiddleware(options.RetryObserver))
+		})
+	}
+
+	timeout := options.OperationTimeout
+	if timeout == 0 {
+		timeout = DefaultOperationTimeout
+	}
+
 	return &ParameterStore{
-		Client: ssm.NewFromConfig(cfg),
+		Client:           ssm.NewFromConfig(cfg, clientOpts...),
+		OperationTimeout: timeout,
 	}, nil
 }
 
-func BuildPutParameterInput(paramName, paramValue, keyId string, overwrite bool) *ssm.PutParameterInput {
-	return &ssm.PutParameterInput{
-		Name:      aws.String(paramName),
-		Value:     aws.String(paramValue),
-		Type:      types.ParameterTypeSecureString,
+func BuildPutParameterInput(param Parameter, keyId string, overwrite bool) *ssm.PutParameterInput {
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(param.Name),
+		Value:     aws.String(param.Value),
+		Type:      param.EffectiveType(),
 		Overwrite: aws.Bool(overwrite),
-		KeyId:     aws.String(keyId),
+		Tier:      param.EffectiveTier(),
+	}
+
+	// an overwrite sends an empty list when there are no policies, which clears old ones
+	if policies := param.Policies.JSON(); policies != "" {
+		input.Policies = aws.String(policies)
+	} else if overwrite {
+		input.Policies = aws.String("[]")
+	}
+
+	// an overwrite sends the description even when empty, which clears an old one
+	if param.Description != "" || overwrite {
+		input.Description = aws.String(param.Description)
+	}
+
+	// a KMS key is only valid for secure strings
+	if input.Type == types.ParameterTypeSecureString {
+		input.KeyId = aws.String(keyId)
 	}
+	return input
 }
 
 func BuildGetParamsByPathInput(path, next string, decrypt bool) *ssm.GetParametersByPathInput {
 	return &ssm.GetParametersByPathInput{
 		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
 		WithDecryption: aws.Bool(decrypt),
 		NextToken:      aws.String(next),
 		MaxResults:     aws.Int32(10),
 	}
 }
 
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func BuildAddTagsInput(name string, tags map[string]string) *ssm.AddTagsToResourceInput {
+	input := &ssm.AddTagsToResourceInput{
+		ResourceId:   aws.String(name),
+		ResourceType: types.ResourceTypeForTaggingParameter,
+	}
+	for _, key := range sortedKeys(tags) {
+		input.Tags = append(input.Tags, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return input
+}
+
+func BuildRemoveTagsInput(name string, keys []string) *ssm.RemoveTagsFromResourceInput {
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	return &ssm.RemoveTagsFromResourceInput{
+		ResourceId:   aws.String(name),
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		TagKeys:      keys,
+	}
+}
+
+func BuildListTagsInput(name string) *ssm.ListTagsForResourceInput {
+	return &ssm.ListTagsForResourceInput{
+		ResourceId:   aws.String(name),
+		ResourceType: types.ResourceTypeForTaggingParameter,
+	}
+}
+
 func BuildDeleteParamsInput(names []string) *ssm.DeleteParametersInput {
 	return &ssm.DeleteParametersInput{
 		Names: names,
@@ -74,62 +367,157 @@ func BuildDescribeParametersInput(paths ...string) *ssm.DescribeParametersInput
 	}
 }
 
-func (p *ParameterStore) DescribeParameters(paths ...string) ([]string, error) {
+func BuildDescribeParametersByPathInput(path, next string) *ssm.DescribeParametersInput {
+	input := &ssm.DescribeParametersInput{
+		ParameterFilters: []types.ParameterStringFilter{
+			{
+				Key:    aws.String("Path"),
+				Option: aws.String("Recursive"),
+				Values: []string{path},
+			},
+		},
+	}
+	if next != "" {
+		input.NextToken = aws.String(next)
+	}
+	return input
+}
+
+func BuildSearchByTagsInput(path string, tags map[string]string, next string) *ssm.DescribeParametersInput {
+	input := &ssm.DescribeParametersInput{
+		ParameterFilters: []types.ParameterStringFilter{
+			{
+				Key:    aws.String("Path"),
+				Option: aws.String("Recursive"),
+				Values: []string{path},
+			},
+		},
+	}
+	for _, key := range sortedKeys(tags) {
+		input.ParameterFilters = append(input.ParameterFilters, types.ParameterStringFilter{
+			Key:    aws.String("tag:" + key),
+			Option: aws.String("Equals"),
+			Values: []string{tags[key]},
+		})
+	}
+	if next != "" {
+		input.NextToken = aws.String(next)
+	}
+	return input
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// DescribeParameters returns the names of all parameters that begin with any of the paths,
+// following every page of results
+func (p *ParameterStore) DescribeParameters(ctx context.Context, paths ...string) ([]string, error) {
 
 	var names []string
 
 	input := BuildDescribeParametersInput(paths...)
 
-	result, err := p.Client.DescribeParameters(ctx, input)
-	if err != nil {
-		return names, fmt.Errorf("error describing parameters: %s", err)
-	}
-	for _, param := range result.Parameters {
-		names = append(names, *param.Name)
+	for {
+		opCtx, cancel := p.operationContext(ctx)
+		result, err := p.Client.DescribeParameters(opCtx, input)
+		cancel()
+		if err != nil {
+			return names, fmt.Errorf("error describing parameters: %s", err)
+		}
+		for _, param := range result.Parameters {
+			names = append(names, *param.Name)
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
 	}
 
 	return names, nil
 }
 
-func (p *ParameterStore) PutParameters(params map[string]string, keyId string, overwrite bool) error {
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// PutParameters puts every parameter and returns the version each one was written as
+func (p *ParameterStore) PutParameters(ctx context.Context, params map[string]Parameter, keyId string, overwrite bool) (map[string]int64, error) {
+	versions := make(map[string]int64)
 
-	for paramKey, paramValue := range params {
-		params := BuildPutParameterInput(paramKey, paramValue, keyId, overwrite)
-		result, err := p.Client.PutParameter(ctx, params)
+	for paramKey, param := range params {
+		param.Name = paramKey
+		params := BuildPutParameterInput(param, keyId, overwrite)
+		opCtx, cancel := p.operationContext(ctx)
+		result, err := p.Client.PutParameter(opCtx, params)
+		cancel()
 
 		if err != nil {
 
-			return fmt.Errorf("Error putting parameter %s: %s", paramKey, err)
+			return versions, fmt.Errorf("Error putting parameter %s: %s", paramKey, err)
 		}
 		fmt.Printf("Parameter added: %s Version: %d\n", *params.Name, result.Version)
+		versions[paramKey] = result.Version
+
+		if len(param.Tags) > 0 {
+			opCtx, cancel := p.operationContext(ctx)
+			_, err = p.Client.AddTagsToResource(opCtx, BuildAddTagsInput(paramKey, param.Tags))
+			cancel()
+			if err != nil {
+				return versions, fmt.Errorf("Error tagging parameter %s: %s", paramKey, err)
+			}
+		}
+
+		// an overwritten parameter may carry tags that were removed locally since
+		if overwrite {
+			if err := p.removeStaleTags(ctx, paramKey, param.Tags); err != nil {
+				return versions, fmt.Errorf("Error untagging parameter %s: %s", paramKey, err)
+			}
+		}
 	}
-	return nil
+	return versions, nil
 }
 
-func (p *ParameterStore) GetParameters(path string, decrypt bool) (map[string]string, error) {
+// removeStaleTags removes the tags of a parameter that are not in tags
+func (p *ParameterStore) removeStaleTags(ctx context.Context, name string, tags map[string]string) error {
+	opCtx, cancel := p.operationContext(ctx)
+	result, err := p.Client.ListTagsForResource(opCtx, BuildListTagsInput(name))
+	cancel()
+	if err != nil {
+		return err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	var stale []string
+	for _, tag := range result.TagList {
+		if _, ok := tags[aws.ToString(tag.Key)]; !ok {
+			stale = append(stale, aws.ToString(tag.Key))
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	opCtx, cancel = p.operationContext(ctx)
 	defer cancel()
+	_, err = p.Client.RemoveTagsFromResource(opCtx, BuildRemoveTagsInput(name, stale))
+	return err
+}
+
+func (p *ParameterStore) GetParameters(ctx context.Context, path string, decrypt bool) (map[string]Parameter, error) {
 
 	next := ""
-	params := make(map[string]string)
+	params := make(map[string]Parameter)
 
 	for {
 		input := BuildGetParamsByPathInput(path, next, decrypt)
-		result, err := p.Client.GetParametersByPath(ctx, input)
+		opCtx, cancel := p.operationContext(ctx)
+		result, err := p.Client.GetParametersByPath(opCtx, input)
+		cancel()
 
 		if err != nil {
 			return nil, fmt.Errorf("error getting parameters: %s", err)
 		}
 
 		for _, param := range result.Parameters {
-			params[*param.Name] = *param.Value
+			params[*param.Name] = Parameter{
+				Name:    *param.Name,
+				Value:   *param.Value,
+				Type:    param.Type,
+				Version: param.Version,
+			}
 		}
 
 		if result.NextToken == nil {
@@ -140,27 +528,154 @@ func (p *ParameterStore) GetParameters(path string, decrypt bool) (map[string]st
 	return params, nil
 }
 
-func (p *ParameterStore) DeleteParameters(names []string) error {
+// GetParametersWithMetadata gets the parameters on a path along with their descriptions, tags, tiers and policies
+func (p *ParameterStore) GetParametersWithMetadata(ctx context.Context, path string, decrypt bool) (map[string]Parameter, error) {
+	params, err := p.GetParameters(ctx, path, decrypt)
+	if err != nil {
+		return nil, err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	next := ""
+	for {
+		opCtx, cancel := p.operationContext(ctx)
+		result, err := p.Client.DescribeParameters(opCtx, BuildDescribeParametersByPathInput(path, next))
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("error describing parameters: %s", err)
+		}
 
-	input := BuildDeleteParamsInput(names)
-	result, err := p.Client.DeleteParameters(ctx, input)
+		for _, meta := range result.Parameters {
+			param, ok := params[aws.ToString(meta.Name)]
+			if !ok {
+				continue
+			}
+			param.Description = aws.ToString(meta.Description)
+			param.Tier = meta.Tier
+			param.Policies = ParsePolicies(meta.Policies)
+			param.KeyID = aws.ToString(meta.KeyId)
+			params[param.Name] = param
+		}
 
-	if err != nil {
-		return fmt.Errorf("Error deleting parameters: %s", err)
+		if result.NextToken == nil {
+			break
+		}
+		next = *result.NextToken
+	}
+
+	for name, param := range params {
+		opCtx, cancel := p.operationContext(ctx)
+		result, err := p.Client.ListTagsForResource(opCtx, BuildListTagsInput(name))
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("error listing tags for parameter %s: %s", name, err)
+		}
+		for _, tag := range result.TagList {
+			if param.Tags == nil {
+				param.Tags = make(map[string]string)
+			}
+			param.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		params[name] = param
+	}
+	return params, nil
+}
+
+// SearchByTags finds all parameters below a path that have every one of the given tags
+func (p *ParameterStore) SearchByTags(ctx context.Context, path string, tags map[string]string) ([]Parameter, error) {
+
+	var params []Parameter
+	next := ""
+
+	for {
+		opCtx, cancel := p.operationContext(ctx)
+		result, err := p.Client.DescribeParameters(opCtx, BuildSearchByTagsInput(path, tags, next))
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("error searching parameters: %s", err)
+		}
+
+		for _, meta := range result.Parameters {
+			params = append(params, Parameter{
+				Name:        aws.ToString(meta.Name),
+				Type:        meta.Type,
+				Version:     meta.Version,
+				Description: aws.ToString(meta.Description),
+			})
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		next = *result.NextToken
 	}
+	return params, nil
+}
 
-	for _, deleted := range result.DeletedParameters {
-		fmt.Printf("Parameter deleted: %s\n", deleted)
+// DeleteParametersError reports the parameters that were not deleted. Invalid holds
+// the parameters the parameter store rejected, Failed the ones in batches that errored.
+type DeleteParametersError struct {
+	Deleted []string
+	Invalid []string
+	Failed  []string
+	Err     error
+}
+
+func (e *DeleteParametersError) Error() string {
+	var parts []string
+	if len(e.Invalid) > 0 {
+		parts = append(parts, fmt.Sprintf("invalid parameters: %s", strings.Join(e.Invalid, ", ")))
+	}
+	if len(e.Failed) > 0 {
+		parts = append(parts, fmt.Sprintf("failed to delete: %s (%s)", strings.Join(e.Failed, ", "), e.Err))
 	}
+	return fmt.Sprintf("Error deleting parameters, %d deleted, %s", len(e.Deleted), strings.Join(parts, "; "))
+}
+
+func (e *DeleteParametersError) Unwrap() error {
+	return e.Err
+}
 
+// DeleteParameters deletes the parameters in batches of MaxDeleteBatchSize. Every batch is
+// attempted, and a *DeleteParametersError lists any parameters that were not deleted.
+func (p *ParameterStore) DeleteParameters(ctx context.Context, names []string) error {
+
+	report := &DeleteParametersError{}
+
+	for start := 0; start < len(names); start += MaxDeleteBatchSize {
+		batch := names[start:min(start+MaxDeleteBatchSize, len(names))]
+
+		input := BuildDeleteParamsInput(batch)
+		opCtx, cancel := p.operationContext(ctx)
+		result, err := p.Client.DeleteParameters(opCtx, input)
+		cancel()
+
+		if err != nil {
+			report.Failed = append(report.Failed, batch...)
+			report.Err = errors.Join(report.Err, err)
+
+			// once the caller gives up there is no point attempting the remaining batches
+			if ctx.Err() != nil {
+				report.Failed = append(report.Failed, names[start+len(batch):]...)
+				break
+			}
+			continue
+		}
+
+		for _, deleted := range result.DeletedParameters {
+			fmt.Printf("Parameter deleted: %s\n", deleted)
+		}
+		report.Deleted = append(report.Deleted, result.DeletedParameters...)
+		report.Invalid = append(report.Invalid, result.InvalidParameters...)
+	}
+
+	if len(report.Invalid) > 0 || len(report.Failed) > 0 {
+		return report
+	}
 	return nil
 }
 
-func CheckCredentials() error {
-	_, err := New()
+func CheckCredentials(opts ...Option) error {
+	_, err := New(opts...)
 	if err != nil {
 		return fmt.Errorf("unable to load AWS SDK config, %v", err)
 	}
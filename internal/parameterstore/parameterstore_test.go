@@ -3,12 +3,14 @@ package parameterstore
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 type MockSSMClient struct {
@@ -35,6 +37,21 @@ func (m *MockSSMClient) DeleteParameters(ctx context.Context, input *ssm.DeleteP
 	return args.Get(0).(*ssm.DeleteParametersOutput), args.Error(1)
 }
 
+func (m *MockSSMClient) AddTagsToResource(ctx context.Context, input *ssm.AddTagsToResourceInput, opts ...func(*ssm.Options)) (*ssm.AddTagsToResourceOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ssm.AddTagsToResourceOutput), args.Error(1)
+}
+
+func (m *MockSSMClient) ListTagsForResource(ctx context.Context, input *ssm.ListTagsForResourceInput, opts ...func(*ssm.Options)) (*ssm.ListTagsForResourceOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ssm.ListTagsForResourceOutput), args.Error(1)
+}
+
+func (m *MockSSMClient) RemoveTagsFromResource(ctx context.Context, input *ssm.RemoveTagsFromResourceInput, opts ...func(*ssm.Options)) (*ssm.RemoveTagsFromResourceOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ssm.RemoveTagsFromResourceOutput), args.Error(1)
+}
+
 func NewMockParameterStore() *ParameterStore {
 	return &ParameterStore{
 		Client: &MockSSMClient{},
@@ -52,7 +69,7 @@ func TestDescribeParametersReturnsNames(t *testing.T) {
 		},
 	}, nil)
 
-	names, err := ps.DescribeParameters("/path")
+	names, err := ps.DescribeParameters(context.Background(), "/path")
 	require.NoError(t, err)
 	require.ElementsMatch(t, []string{"param1", "param2"}, names)
 }
@@ -63,7 +80,7 @@ func TestDescribeParametersHandlesError(t *testing.T) {
 
 	mockClient.On("DescribeParameters", mock.Anything, mock.Anything).Return((*ssm.DescribeParametersOutput)(nil), errors.New("error"))
 
-	names, err := ps.DescribeParameters("/path")
+	names, err := ps.DescribeParameters(context.Background(), "/path")
 	require.Error(t, err)
 	require.Empty(t, names)
 }
@@ -75,8 +92,9 @@ func TestPutParametersSuccessfully(t *testing.T) {
 	mockClient.On("PutParameter", mock.Anything, mock.Anything).Return(&ssm.PutParameterOutput{
 		Version: *aws.Int64(1),
 	}, nil)
+	mockClient.On("ListTagsForResource", mock.Anything, BuildListTagsInput("param1")).Return(&ssm.ListTagsForResourceOutput{}, nil)
 
-	err := ps.PutParameters(map[string]string{"param1": "value1"}, "keyId", true)
+	_, err := ps.PutParameters(context.Background(), map[string]Parameter{"param1": {Value: "value1"}}, "keyId", true)
 	require.NoError(t, err)
 }
 
@@ -86,7 +104,7 @@ func TestPutParametersHandlesError(t *testing.T) {
 
 	mockClient.On("PutParameter", mock.Anything, mock.Anything).Return((*ssm.PutParameterOutput)(nil), errors.New("error"))
 
-	err := ps.PutParameters(map[string]string{"param1": "value1"}, "keyId", true)
+	_, err := ps.PutParameters(context.Background(), map[string]Parameter{"param1": {Value: "value1"}}, "keyId", true)
 	require.Error(t, err)
 }
 
@@ -96,14 +114,17 @@ func TestGetParametersReturnsValues(t *testing.T) {
 
 	mockClient.On("GetParametersByPath", mock.Anything, mock.Anything).Return(&ssm.GetParametersByPathOutput{
 		Parameters: []types.Parameter{
-			{Name: aws.String("param1"), Value: aws.String("value1")},
-			{Name: aws.String("param2"), Value: aws.String("value2")},
+			{Name: aws.String("param1"), Value: aws.String("value1"), Type: types.ParameterTypeSecureString, Version: 1},
+			{Name: aws.String("param2"), Value: aws.String("a,b"), Type: types.ParameterTypeStringList, Version: 3},
 		},
 	}, nil)
 
-	params, err := ps.GetParameters("/path", true)
+	params, err := ps.GetParameters(context.Background(), "/path", true)
 	require.NoError(t, err)
-	require.Equal(t, map[string]string{"param1": "value1", "param2": "value2"}, params)
+	require.Equal(t, map[string]Parameter{
+		"param1": {Name: "param1", Value: "value1", Type: types.ParameterTypeSecureString, Version: 1},
+		"param2": {Name: "param2", Value: "a,b", Type: types.ParameterTypeStringList, Version: 3},
+	}, params)
 }
 
 func TestGetParametersHandlesError(t *testing.T) {
@@ -112,7 +133,7 @@ func TestGetParametersHandlesError(t *testing.T) {
 
 	mockClient.On("GetParametersByPath", mock.Anything, mock.Anything).Return((*ssm.GetParametersByPathOutput)(nil), errors.New("error"))
 
-	params, err := ps.GetParameters("/path", true)
+	params, err := ps.GetParameters(context.Background(), "/path", true)
 	require.Error(t, err)
 	require.Empty(t, params)
 }
@@ -125,7 +146,7 @@ func TestDeleteParametersSuccessfully(t *testing.T) {
 		DeletedParameters: []string{"param1", "param2"},
 	}, nil)
 
-	err := ps.DeleteParameters([]string{"param1", "param2"})
+	err := ps.DeleteParameters(context.Background(), []string{"param1", "param2"})
 	require.NoError(t, err)
 }
 
@@ -135,6 +156,260 @@ func TestDeleteParametersHandlesError(t *testing.T) {
 
 	mockClient.On("DeleteParameters", mock.Anything, mock.Anything).Return((*ssm.DeleteParametersOutput)(nil), errors.New("error"))
 
-	err := ps.DeleteParameters([]string{"param1", "param2"})
+	err := ps.DeleteParameters(context.Background(), []string{"param1", "param2"})
 	require.Error(t, err)
 }
+
+func TestBuildPutParameterInputDefaultsToSecureString(t *testing.T) {
+	input := BuildPutParameterInput(Parameter{Name: "param1", Value: "value1"}, "keyId", false)
+	require.Equal(t, types.ParameterTypeSecureString, input.Type)
+	require.Equal(t, "keyId", *input.KeyId)
+}
+
+func TestBuildPutParameterInputOmitsKeyIdForPlainTypes(t *testing.T) {
+	input := BuildPutParameterInput(Parameter{Name: "param1", Value: "a,b", Type: types.ParameterTypeStringList}, "keyId", true)
+	require.Equal(t, types.ParameterTypeStringList, input.Type)
+	require.Nil(t, input.KeyId)
+}
+
+func TestBuildPutParameterInputClearsPolicies(t *testing.T) {
+	input := BuildPutParameterInput(Parameter{Name: "param1", Value: "value1"}, "keyId", true)
+	require.Equal(t, "[]", *input.Policies)
+
+	input = BuildPutParameterInput(Parameter{Name: "param1", Value: "value1"}, "keyId", false)
+	require.Nil(t, input.Policies)
+}
+
+func TestParameterDiffers(t *testing.T) {
+	require.False(t, Parameter{Value: "v"}.Differs(Parameter{Value: "v", Type: types.ParameterTypeSecureString}))
+	require.True(t, Parameter{Value: "v"}.Differs(Parameter{Value: "v", Type: types.ParameterTypeString}))
+	require.True(t, Parameter{Value: "v"}.Differs(Parameter{Value: "w"}))
+
+	// clearing a tag or the description is a change
+	tagged := Parameter{Value: "v", Description: "d", Tags: map[string]string{ProjectTagKey: "foobar", "team": "platform"}}
+	require.True(t, Parameter{Value: "v", Description: "d", Tags: map[string]string{ProjectTagKey: "foobar"}}.Differs(tagged))
+	require.True(t, Parameter{Value: "v", Tags: tagged.Tags}.Differs(tagged))
+	require.False(t, Parameter{Value: "v", Description: "d", Tags: map[string]string{ProjectTagKey: "foobar", "team": "platform"}}.Differs(tagged))
+}
+
+func TestPutParametersRemovesStaleTags(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+
+	mockClient.On("PutParameter", mock.Anything, mock.MatchedBy(func(input *ssm.PutParameterInput) bool {
+		return input.Description != nil && *input.Description == ""
+	})).Return(&ssm.PutParameterOutput{Version: 2}, nil)
+	mockClient.On("AddTagsToResource", mock.Anything, mock.Anything).Return(&ssm.AddTagsToResourceOutput{}, nil)
+	mockClient.On("ListTagsForResource", mock.Anything, BuildListTagsInput("param1")).Return(&ssm.ListTagsForResourceOutput{
+		TagList: []types.Tag{
+			{Key: aws.String(ProjectTagKey), Value: aws.String("foobar")},
+			{Key: aws.String("team"), Value: aws.String("platform")},
+		},
+	}, nil)
+	mockClient.On("RemoveTagsFromResource", mock.Anything, BuildRemoveTagsInput("param1", []string{"team"})).Return(&ssm.RemoveTagsFromResourceOutput{}, nil)
+
+	_, err := ps.PutParameters(context.Background(), map[string]Parameter{"param1": {
+		Value: "value1",
+		Tags:  map[string]string{ProjectTagKey: "foobar"},
+	}}, "keyId", true)
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestPutParametersAddsTags(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+
+	mockClient.On("PutParameter", mock.Anything, mock.MatchedBy(func(input *ssm.PutParameterInput) bool {
+		return *input.Description == "the database password"
+	})).Return(&ssm.PutParameterOutput{Version: 1}, nil)
+	mockClient.On("AddTagsToResource", mock.Anything, BuildAddTagsInput("param1", map[string]string{OwnerTagKey: "payments", ProjectTagKey: "foobar"})).Return(&ssm.AddTagsToResourceOutput{}, nil)
+	mockClient.On("ListTagsForResource", mock.Anything, BuildListTagsInput("param1")).Return(&ssm.ListTagsForResourceOutput{
+		TagList: []types.Tag{
+			{Key: aws.String(OwnerTagKey), Value: aws.String("payments")},
+			{Key: aws.String(ProjectTagKey), Value: aws.String("foobar")},
+		},
+	}, nil)
+
+	_, err := ps.PutParameters(context.Background(), map[string]Parameter{"param1": {
+		Value:       "value1",
+		Description: "the database password",
+		Tags:        map[string]string{OwnerTagKey: "payments", ProjectTagKey: "foobar"},
+	}}, "keyId", true)
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestGetParametersWithMetadataReturnsDescriptionsAndTags(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+
+	mockClient.On("GetParametersByPath", mock.Anything, mock.Anything).Return(&ssm.GetParametersByPathOutput{
+		Parameters: []types.Parameter{
+			{Name: aws.String("/path/param1"), Value: aws.String("value1"), Type: types.ParameterTypeString, Version: 2},
+		},
+	}, nil)
+	mockClient.On("DescribeParameters", mock.Anything, mock.Anything).Return(&ssm.DescribeParametersOutput{
+		Parameters: []types.ParameterMetadata{
+			{Name: aws.String("/path/param1"), Description: aws.String("a description")},
+		},
+	}, nil)
+	mockClient.On("ListTagsForResource", mock.Anything, BuildListTagsInput("/path/param1")).Return(&ssm.ListTagsForResourceOutput{
+		TagList: []types.Tag{{Key: aws.String(OwnerTagKey), Value: aws.String("payments")}},
+	}, nil)
+
+	params, err := ps.GetParametersWithMetadata(context.Background(), "/path", true)
+	require.NoError(t, err)
+	require.Equal(t, Parameter{
+		Name:        "/path/param1",
+		Value:       "value1",
+		Type:        types.ParameterTypeString,
+		Version:     2,
+		Description: "a description",
+		Tags:        map[string]string{OwnerTagKey: "payments"},
+	}, params["/path/param1"])
+}
+
+func TestSearchByTagsFollowsPages(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+	tags := map[string]string{OwnerTagKey: "payments"}
+
+	mockClient.On("DescribeParameters", mock.Anything, BuildSearchByTagsInput("/path", tags, "")).Return(&ssm.DescribeParametersOutput{
+		Parameters: []types.ParameterMetadata{{Name: aws.String("/path/dev/param1")}},
+		NextToken:  aws.String("next"),
+	}, nil)
+	mockClient.On("DescribeParameters", mock.Anything, BuildSearchByTagsInput("/path", tags, "next")).Return(&ssm.DescribeParametersOutput{
+		Parameters: []types.ParameterMetadata{{Name: aws.String("/path/prod/param1")}},
+	}, nil)
+
+	params, err := ps.SearchByTags(context.Background(), "/path", tags)
+	require.NoError(t, err)
+	require.Len(t, params, 2)
+	require.Equal(t, "tag:owner", *BuildSearchByTagsInput("/path", tags, "").ParameterFilters[1].Key)
+}
+
+func TestDescribeParametersFollowsPages(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+
+	mockClient.On("DescribeParameters", mock.Anything, mock.MatchedBy(func(input *ssm.DescribeParametersInput) bool {
+		return input.NextToken == nil
+	})).Return(&ssm.DescribeParametersOutput{
+		Parameters: []types.ParameterMetadata{{Name: aws.String("param1")}},
+		NextToken:  aws.String("page2"),
+	}, nil).Once()
+	mockClient.On("DescribeParameters", mock.Anything, mock.MatchedBy(func(input *ssm.DescribeParametersInput) bool {
+		return aws.ToString(input.NextToken) == "page2"
+	})).Return(&ssm.DescribeParametersOutput{
+		Parameters: []types.ParameterMetadata{{Name: aws.String("param2")}},
+	}, nil).Once()
+
+	names, err := ps.DescribeParameters(context.Background(), "/path")
+	require.NoError(t, err)
+	require.Equal(t, []string{"param1", "param2"}, names)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDeleteParametersBatchesNames(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+
+	var names []string
+	for i := 0; i < 25; i++ {
+		names = append(names, fmt.Sprintf("param%d", i))
+	}
+
+	mockClient.On("DeleteParameters", mock.Anything, mock.MatchedBy(func(input *ssm.DeleteParametersInput) bool {
+		return len(input.Names) <= MaxDeleteBatchSize
+	})).Return(&ssm.DeleteParametersOutput{}, nil)
+
+	err := ps.DeleteParameters(context.Background(), names)
+	require.NoError(t, err)
+	mockClient.AssertNumberOfCalls(t, "DeleteParameters", 3)
+}
+
+func TestDeleteParametersReportsInvalidParameters(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+
+	mockClient.On("DeleteParameters", mock.Anything, mock.Anything).Return(&ssm.DeleteParametersOutput{
+		DeletedParameters: []string{"param1"},
+		InvalidParameters: []string{"param2"},
+	}, nil)
+
+	err := ps.DeleteParameters(context.Background(), []string{"param1", "param2"})
+
+	var deleteErr *DeleteParametersError
+	require.ErrorAs(t, err, &deleteErr)
+	require.Equal(t, []string{"param1"}, deleteErr.Deleted)
+	require.Equal(t, []string{"param2"}, deleteErr.Invalid)
+	require.Contains(t, err.Error(), "param2")
+}
+
+func TestDeleteParametersContinuesAfterFailedBatch(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+
+	var names []string
+	for i := 0; i < 15; i++ {
+		names = append(names, fmt.Sprintf("param%d", i))
+	}
+
+	mockClient.On("DeleteParameters", mock.Anything, mock.Anything).Return((*ssm.DeleteParametersOutput)(nil), errors.New("throttled")).Once()
+	mockClient.On("DeleteParameters", mock.Anything, mock.Anything).Return(&ssm.DeleteParametersOutput{
+		DeletedParameters: names[10:],
+	}, nil).Once()
+
+	err := ps.DeleteParameters(context.Background(), names)
+
+	var deleteErr *DeleteParametersError
+	require.ErrorAs(t, err, &deleteErr)
+	require.Equal(t, names[:10], deleteErr.Failed)
+	require.Equal(t, names[10:], deleteErr.Deleted)
+}
+
+func TestGetParametersAppliesOperationTimeoutPerRequest(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient, OperationTimeout: time.Minute}
+
+	hasDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	})
+	mockClient.On("GetParametersByPath", hasDeadline, mock.Anything).Return(&ssm.GetParametersByPathOutput{
+		Parameters: []types.Parameter{{Name: aws.String("/path/one"), Value: aws.String("1")}},
+		NextToken:  aws.String("next"),
+	}, nil).Once()
+	mockClient.On("GetParametersByPath", hasDeadline, mock.Anything).Return(&ssm.GetParametersByPathOutput{
+		Parameters: []types.Parameter{{Name: aws.String("/path/two"), Value: aws.String("2")}},
+	}, nil).Once()
+
+	params, err := ps.GetParameters(context.Background(), "/path", true)
+	require.NoError(t, err)
+	require.Len(t, params, 2)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDeleteParametersStopsWhenCancelled(t *testing.T) {
+	mockClient := new(MockSSMClient)
+	ps := &ParameterStore{Client: mockClient}
+
+	var names []string
+	for i := 0; i < 25; i++ {
+		names = append(names, fmt.Sprintf("param%d", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockClient.On("DeleteParameters", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		cancel()
+	}).Return((*ssm.DeleteParametersOutput)(nil), context.Canceled).Once()
+
+	err := ps.DeleteParameters(ctx, names)
+
+	var deleteErr *DeleteParametersError
+	require.ErrorAs(t, err, &deleteErr)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, names, deleteErr.Failed)
+	mockClient.AssertNumberOfCalls(t, "DeleteParameters", 1)
+}
@@ -0,0 +1,164 @@
+package parameterstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// StandardTierMaxSize is the largest value in bytes that fits in the standard tier
+const StandardTierMaxSize = 4096
+
+const policyTimestampFormat = "2006-01-02T15:04:05.000Z"
+
+// Policies are the parameter policies that can be attached to a parameter.
+// Expiration is a UTC timestamp, the notifications are durations like "15 days".
+type Policies struct {
+	Expiration             string
+	ExpirationNotification string
+	NoChangeNotification   string
+}
+
+type policy struct {
+	Type       string            `json:"Type"`
+	Version    string            `json:"Version"`
+	Attributes map[string]string `json:"Attributes"`
+}
+
+// NewPolicies validates the given policy values and returns them in normalised form
+func NewPolicies(expiration, expirationNotification, noChangeNotification string) (Policies, error) {
+	var policies Policies
+
+	if expiration != "" {
+		t, err := time.Parse(time.RFC3339, expiration)
+		if err != nil {
+			return policies, fmt.Errorf("invalid expiration %q, must be an RFC3339 timestamp", expiration)
+		}
+		policies.Expiration = t.UTC().Format(policyTimestampFormat)
+	}
+
+	if expirationNotification != "" {
+		amount, unit, err := parsePolicyDuration(expirationNotification)
+		if err != nil {
+			return policies, fmt.Errorf("invalid expiration notification: %s", err)
+		}
+		policies.ExpirationNotification = formatPolicyDuration(amount, unit)
+	}
+
+	if noChangeNotification != "" {
+		amount, unit, err := parsePolicyDuration(noChangeNotification)
+		if err != nil {
+			return policies, fmt.Errorf("invalid no change notification: %s", err)
+		}
+		policies.NoChangeNotification = formatPolicyDuration(amount, unit)
+	}
+
+	if policies.ExpirationNotification != "" && policies.Expiration == "" {
+		return policies, fmt.Errorf("an expiration notification requires an expiration")
+	}
+	return policies, nil
+}
+
+// IsEmpty reports whether no policies are set
+func (p Policies) IsEmpty() bool {
+	return p == Policies{}
+}
+
+// JSON returns the policies in the json format expected by the parameter store
+func (p Policies) JSON() string {
+	var policies []policy
+
+	if p.Expiration != "" {
+		policies = append(policies, policy{
+			Type:       "Expiration",
+			Version:    "1.0",
+			Attributes: map[string]string{"Timestamp": p.Expiration},
+		})
+	}
+
+	if p.ExpirationNotification != "" {
+		amount, unit, _ := parsePolicyDuration(p.ExpirationNotification)
+		policies = append(policies, policy{
+			Type:       "ExpirationNotification",
+			Version:    "1.0",
+			Attributes: map[string]string{"Before": strconv.Itoa(amount), "Unit": unit},
+		})
+	}
+
+	if p.NoChangeNotification != "" {
+		amount, unit, _ := parsePolicyDuration(p.NoChangeNotification)
+		policies = append(policies, policy{
+			Type:       "NoChangeNotification",
+			Version:    "1.0",
+			Attributes: map[string]string{"After": strconv.Itoa(amount), "Unit": unit},
+		})
+	}
+
+	if len(policies) == 0 {
+		return ""
+	}
+
+	data, _ := json.Marshal(policies)
+	return string(data)
+}
+
+// ParsePolicies converts the inline policies returned by the parameter store into Policies
+func ParsePolicies(inline []types.ParameterInlinePolicy) Policies {
+	var policies Policies
+
+	for _, p := range inline {
+		if p.PolicyText == nil {
+			continue
+		}
+
+		var parsed policy
+		if err := json.Unmarshal([]byte(*p.PolicyText), &parsed); err != nil {
+			continue
+		}
+
+		switch parsed.Type {
+		case "Expiration":
+			if t, err := time.Parse(time.RFC3339, parsed.Attributes["Timestamp"]); err == nil {
+				policies.Expiration = t.UTC().Format(policyTimestampFormat)
+			}
+		case "ExpirationNotification":
+			if amount, err := strconv.Atoi(parsed.Attributes["Before"]); err == nil {
+				policies.ExpirationNotification = formatPolicyDuration(amount, parsed.Attributes["Unit"])
+			}
+		case "NoChangeNotification":
+			if amount, err := strconv.Atoi(parsed.Attributes["After"]); err == nil {
+				policies.NoChangeNotification = formatPolicyDuration(amount, parsed.Attributes["Unit"])
+			}
+		}
+	}
+	return policies
+}
+
+// parsePolicyDuration parses durations like "15 days" or "12 hours" into an amount and a policy unit
+func parsePolicyDuration(value string) (int, string, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0, "", fmt.Errorf("%q must be in the form '<number> days' or '<number> hours'", value)
+	}
+
+	amount, err := strconv.Atoi(fields[0])
+	if err != nil || amount <= 0 {
+		return 0, "", fmt.Errorf("%q must start with a positive number", value)
+	}
+
+	switch strings.ToLower(strings.TrimSuffix(fields[1], "s")) {
+	case "day":
+		return amount, "Days", nil
+	case "hour":
+		return amount, "Hours", nil
+	}
+	return 0, "", fmt.Errorf("%q must use days or hours as the unit", value)
+}
+
+func formatPolicyDuration(amount int, unit string) string {
+	return fmt.Sprintf("%d %s", amount, strings.ToLower(unit))
+}
@@ -0,0 +1,67 @@
+package parameterstore
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPoliciesNormalisesValues(t *testing.T) {
+	policies, err := NewPolicies("2025-01-01T02:00:00+02:00", "15 Days", "1 hour")
+	require.NoError(t, err)
+	require.Equal(t, Policies{
+		Expiration:             "2025-01-01T00:00:00.000Z",
+		ExpirationNotification: "15 days",
+		NoChangeNotification:   "1 hours",
+	}, policies)
+}
+
+func TestNewPoliciesRejectsInvalidValues(t *testing.T) {
+	_, err := NewPolicies("tomorrow", "", "")
+	require.Error(t, err)
+
+	_, err = NewPolicies("", "", "15 weeks")
+	require.Error(t, err)
+
+	_, err = NewPolicies("", "15 days", "")
+	require.Error(t, err)
+}
+
+func TestPoliciesJSONRoundTrip(t *testing.T) {
+	policies, err := NewPolicies("2025-01-01T00:00:00Z", "15 days", "30 days")
+	require.NoError(t, err)
+
+	text := policies.JSON()
+	require.JSONEq(t, `[
+		{"Type":"Expiration","Version":"1.0","Attributes":{"Timestamp":"2025-01-01T00:00:00.000Z"}},
+		{"Type":"ExpirationNotification","Version":"1.0","Attributes":{"Before":"15","Unit":"Days"}},
+		{"Type":"NoChangeNotification","Version":"1.0","Attributes":{"After":"30","Unit":"Days"}}
+	]`, text)
+
+	inline := []types.ParameterInlinePolicy{
+		{PolicyText: aws.String(`{"Type":"Expiration","Version":"1.0","Attributes":{"Timestamp":"2025-01-01T00:00:00.000Z"}}`)},
+		{PolicyText: aws.String(`{"Type":"ExpirationNotification","Version":"1.0","Attributes":{"Before":"15","Unit":"Days"}}`)},
+		{PolicyText: aws.String(`{"Type":"NoChangeNotification","Version":"1.0","Attributes":{"After":"30","Unit":"Days"}}`)},
+	}
+	require.Equal(t, policies, ParsePolicies(inline))
+	require.Empty(t, Policies{}.JSON())
+}
+
+func TestEffectiveTier(t *testing.T) {
+	require.Equal(t, types.ParameterTierStandard, Parameter{Value: "small"}.EffectiveTier())
+	require.Equal(t, types.ParameterTierAdvanced, Parameter{Value: string(make([]byte, StandardTierMaxSize+1))}.EffectiveTier())
+	require.Equal(t, types.ParameterTierAdvanced, Parameter{Value: "small", Policies: Policies{Expiration: "2025-01-01T00:00:00.000Z"}}.EffectiveTier())
+	require.Equal(t, types.ParameterTierIntelligentTiering, Parameter{Value: "small", Tier: types.ParameterTierIntelligentTiering}.EffectiveTier())
+}
+
+func TestBuildPutParameterInputSetsTierAndPolicies(t *testing.T) {
+	input := BuildPutParameterInput(Parameter{
+		Name:     "param1",
+		Value:    "value1",
+		Policies: Policies{Expiration: "2025-01-01T00:00:00.000Z"},
+	}, "keyId", true)
+	require.Equal(t, types.ParameterTierAdvanced, input.Tier)
+	require.Contains(t, *input.Policies, `"Timestamp":"2025-01-01T00:00:00.000Z"`)
+}
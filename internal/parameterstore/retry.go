@@ -0,0 +1,137 @@
+package parameterstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// retry modes supported by WithRetryMode
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive"
+)
+
+// retry defaults used when no retry options are given. Adaptive mode adds client side
+// rate limiting on top of the jittered exponential backoff of the standard mode.
+const (
+	DefaultRetryMode   = RetryModeAdaptive
+	DefaultMaxAttempts = 5
+	DefaultMaxBackoff  = 20 * time.Second
+)
+
+// WithMaxAttempts sets the most attempts made for a single request, including the first one
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *Options) {
+		o.MaxAttempts = maxAttempts
+	}
+}
+
+// WithRetryMode sets the retry mode, either standard or adaptive
+func WithRetryMode(mode string) Option {
+	return func(o *Options) {
+		o.RetryMode = mode
+	}
+}
+
+// WithMaxBackoff sets the longest delay between two attempts of a request
+func WithMaxBackoff(maxBackoff time.Duration) Option {
+	return func(o *Options) {
+		o.MaxBackoff = maxBackoff
+	}
+}
+
+// WithRetryObserver calls observe for every request that needed more than one attempt
+func WithRetryObserver(observe func(operation string, attempts int)) Option {
+	return func(o *Options) {
+		o.RetryObserver = observe
+	}
+}
+
+// ValidateRetryMode returns an error if the mode is not a supported retry mode
+func ValidateRetryMode(mode string) error {
+	switch mode {
+	case "", RetryModeStandard, RetryModeAdaptive:
+		return nil
+	}
+	return fmt.Errorf("invalid retry mode %q, must be %s or %s", mode, RetryModeStandard, RetryModeAdaptive)
+}
+
+// retryerKey identifies a shared retryer
+type retryerKey struct {
+	Mode        string
+	MaxAttempts int
+	MaxBackoff  time.Duration
+}
+
+// retryers are shared by every client with the same retry options, so the retry
+// token bucket and the adaptive rate limit apply across all workers of a command
+var retryers = struct {
+	sync.Mutex
+	byKey map[retryerKey]aws.Retryer
+}{byKey: make(map[retryerKey]aws.Retryer)}
+
+func newRetryerKey(o Options) retryerKey {
+	key := retryerKey{Mode: o.RetryMode, MaxAttempts: o.MaxAttempts, MaxBackoff: o.MaxBackoff}
+	if key.Mode == "" {
+		key.Mode = DefaultRetryMode
+	}
+	if key.MaxAttempts <= 0 {
+		key.MaxAttempts = DefaultMaxAttempts
+	}
+	if key.MaxBackoff <= 0 {
+		key.MaxBackoff = DefaultMaxBackoff
+	}
+	return key
+}
+
+// sharedRetryer returns the retryer for the options, creating it on first use
+func sharedRetryer(o Options) aws.Retryer {
+	key := newRetryerKey(o)
+
+	retryers.Lock()
+	defer retryers.Unlock()
+
+	if r, ok := retryers.byKey[key]; ok {
+		return r
+	}
+
+	standardOptions := func(so *retry.StandardOptions) {
+		so.MaxAttempts = key.MaxAttempts
+		so.MaxBackoff = key.MaxBackoff
+		so.Backoff = retry.NewExponentialJitterBackoff(key.MaxBackoff)
+	}
+
+	var r aws.Retryer
+	if key.Mode == RetryModeStandard {
+		r = retry.NewStandard(standardOptions)
+	} else {
+		r = retry.NewAdaptiveMode(func(ao *retry.AdaptiveModeOptions) {
+			ao.StandardOptions = append(ao.StandardOptions, standardOptions)
+		})
+	}
+
+	retryers.byKey[key] = r
+	return r
+}
+
+// retryObserverMiddleware reports the number of attempts of every request that was retried
+func retryObserverMiddleware(observe func(operation string, attempts int)) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("PsenvRetryObserver", func(
+			ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+		) (middleware.InitializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			if results, ok := retry.GetAttemptResults(metadata); ok && len(results.Results) > 1 {
+				observe(awsmiddleware.GetOperationName(ctx), len(results.Results))
+			}
+			return out, metadata, err
+		}), middleware.After)
+	}
+}
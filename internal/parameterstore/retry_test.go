@@ -0,0 +1,87 @@
+package parameterstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newThrottlingServer returns a server that throttles the first n requests and then
+// answers every GetParametersByPath with an empty result
+func newThrottlingServer(t *testing.T, n int32) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if atomic.AddInt32(&calls, 1) <= n {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ThrottlingException","message":"Rate exceeded"}`))
+			return
+		}
+		w.Write([]byte(`{"Parameters":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestSharedRetryerIsReused(t *testing.T) {
+	first := sharedRetryer(Options{MaxAttempts: 7})
+	require.Same(t, first, sharedRetryer(Options{MaxAttempts: 7, RetryMode: DefaultRetryMode}))
+	require.NotSame(t, first, sharedRetryer(Options{MaxAttempts: 8}))
+	require.Equal(t, 7, first.MaxAttempts())
+	require.Equal(t, DefaultMaxAttempts, sharedRetryer(Options{}).MaxAttempts())
+}
+
+func TestThrottledRequestsAreRetriedAndObserved(t *testing.T) {
+	ClearConfigCache()
+	defer ClearConfigCache()
+
+	server, calls := newThrottlingServer(t, 2)
+
+	var observed []int
+	ps, err := New(
+		WithEndpointURL(server.URL),
+		WithStaticCredentials("test", "test"),
+		WithRetryMode(RetryModeStandard),
+		WithMaxAttempts(3),
+		WithMaxBackoff(10*time.Millisecond),
+		WithRetryObserver(func(operation string, attempts int) {
+			require.Equal(t, "GetParametersByPath", operation)
+			observed = append(observed, attempts)
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = ps.GetParameters(context.Background(), "/path", false)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(calls))
+	require.Equal(t, []int{3}, observed)
+}
+
+func TestThrottledRequestsFailAfterMaxAttempts(t *testing.T) {
+	ClearConfigCache()
+	defer ClearConfigCache()
+
+	server, calls := newThrottlingServer(t, 10)
+
+	ps, err := New(
+		WithEndpointURL(server.URL),
+		WithStaticCredentials("test", "test"),
+		WithMaxAttempts(2),
+		WithMaxBackoff(10*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	_, err = ps.GetParameters(context.Background(), "/path", false)
+	require.Error(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestNewRejectsInvalidRetryMode(t *testing.T) {
+	_, err := New(WithRetryMode("sometimes"))
+	require.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package terminal
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// values of EnvOptions.Prefer
+const (
+	PreferRemote = "remote"
+	PreferLocal  = "local"
+)
+
+// EnvOptions control how parameters are combined with the environment psenv runs in
+type EnvOptions struct {
+	// Clean starts from an empty environment instead of the one psenv runs in
+	Clean bool
+
+	// Keep lists the inherited variables that are kept when Clean is set
+	Keep []string
+
+	// Prefer decides whether the parameter (remote) or the inherited variable (local)
+	// wins when both have the same name. Empty means remote.
+	Prefer string
+
+	// Only keeps just the parameters with these names
+	Only []string
+
+	// Exclude drops the parameters with these names
+	Exclude []string
+}
+
+// Validate returns an error for an unknown precedence
+func (o EnvOptions) Validate() error {
+	switch o.Prefer {
+	case "", PreferRemote, PreferLocal:
+		return nil
+	}
+	return fmt.Errorf("invalid precedence %q, must be %s or %s", o.Prefer, PreferLocal, PreferRemote)
+}
+
+// Filter applies Only and Exclude to parameters in the NAME=value form
+func (o EnvOptions) Filter(params []string) []string {
+	var filtered []string
+	for _, param := range params {
+		name, _, _ := strings.Cut(param, "=")
+		if len(o.Only) > 0 && !slices.Contains(o.Only, name) {
+			continue
+		}
+		if slices.Contains(o.Exclude, name) {
+			continue
+		}
+		filtered = append(filtered, param)
+	}
+	return filtered
+}
+
+// BuildEnv combines the inherited environment with the parameters, both in the NAME=value
+// form. It returns the environment for the command along with the names of every
+// variable whose value was overridden by the other side.
+func (o EnvOptions) BuildEnv(inherited, params []string) ([]string, []string) {
+	var names []string
+	values := make(map[string]string)
+
+	for _, variable := range inherited {
+		name, value, _ := strings.Cut(variable, "=")
+		if o.Clean && !slices.Contains(o.Keep, name) {
+			continue
+		}
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = value
+	}
+
+	var overridden []string
+	for _, param := range params {
+		name, value, _ := strings.Cut(param, "=")
+		current, ok := values[name]
+		if !ok {
+			names = append(names, name)
+			values[name] = value
+			continue
+		}
+		if current == value {
+			continue
+		}
+
+		overridden = append(overridden, name)
+		if o.Prefer != PreferLocal {
+			values[name] = value
+		}
+	}
+	sort.Strings(overridden)
+
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		env = append(env, name+"="+values[name])
+	}
+	return env, overridden
+}
@@ -0,0 +1,73 @@
+package terminal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildEnvPrefersRemoteByDefault(t *testing.T) {
+	env, overridden := EnvOptions{}.BuildEnv(
+		[]string{"PATH=/bin", "KEY=local", "SAME=value"},
+		[]string{"KEY=remote", "NEW=new", "SAME=value"},
+	)
+
+	expected := []string{"PATH=/bin", "KEY=remote", "SAME=value", "NEW=new"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("expected %v, got %v", expected, env)
+	}
+	if !reflect.DeepEqual(overridden, []string{"KEY"}) {
+		t.Errorf("expected [KEY], got %v", overridden)
+	}
+}
+
+func TestBuildEnvPreferLocal(t *testing.T) {
+	env, overridden := EnvOptions{Prefer: PreferLocal}.BuildEnv(
+		[]string{"KEY=local"},
+		[]string{"KEY=remote"},
+	)
+
+	if !reflect.DeepEqual(env, []string{"KEY=local"}) {
+		t.Errorf("expected [KEY=local], got %v", env)
+	}
+	if !reflect.DeepEqual(overridden, []string{"KEY"}) {
+		t.Errorf("expected [KEY], got %v", overridden)
+	}
+}
+
+func TestBuildEnvClean(t *testing.T) {
+	env, overridden := EnvOptions{Clean: true, Keep: []string{"PATH"}}.BuildEnv(
+		[]string{"PATH=/bin", "HOME=/root", "KEY=local"},
+		[]string{"KEY=remote"},
+	)
+
+	expected := []string{"PATH=/bin", "KEY=remote"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("expected %v, got %v", expected, env)
+	}
+	if len(overridden) != 0 {
+		t.Errorf("expected nothing overridden, got %v", overridden)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	params := []string{"A=1", "B=2", "C=3"}
+
+	only := EnvOptions{Only: []string{"A", "C"}}.Filter(params)
+	if !reflect.DeepEqual(only, []string{"A=1", "C=3"}) {
+		t.Errorf("expected [A=1 C=3], got %v", only)
+	}
+
+	excluded := EnvOptions{Exclude: []string{"B"}}.Filter(params)
+	if !reflect.DeepEqual(excluded, []string{"A=1", "C=3"}) {
+		t.Errorf("expected [A=1 C=3], got %v", excluded)
+	}
+}
+
+func TestValidatePrefer(t *testing.T) {
+	if err := (EnvOptions{Prefer: "sideways"}).Validate(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := (EnvOptions{Prefer: PreferLocal}).Validate(); err != nil {
+		t.Errorf("expected nil, got error %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package terminal
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals that can be sent to a watched child, by name without the SIG prefix
+var signals = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+}
+
+// ForwardedSignals returns the signals psenv relays to the command it runs
+func ForwardedSignals() []os.Signal {
+	return []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package terminal
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals that can be sent to a watched child, by name without the SIG prefix
+var signals = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// ForwardedSignals returns the signals psenv relays to the command it runs. When stdin
+// is a terminal, Ctrl-C and Ctrl-\ already reach the command from the terminal itself,
+// so forwarding them would deliver them twice.
+func ForwardedSignals() []os.Signal {
+	if isTerminal(os.Stdin) {
+		return []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+	}
+	return []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT}
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
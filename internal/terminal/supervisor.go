@@ -0,0 +1,197 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// EnvFileVar is set in the environment of a watched child to the path of its env file
+const EnvFileVar = "PSENV_ENV_FILE"
+
+// DefaultShutdownTimeout is how long a child gets to exit after SIGTERM before it is killed
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Supervisor runs a child process and can restart or signal it while it runs
+type Supervisor struct {
+	// Command creates the child process for a set of environment variables
+	Command func(envVars []string) (*exec.Cmd, error)
+
+	// ShutdownTimeout is how long the child gets to exit after SIGTERM before it is
+	// killed. Zero uses DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	mu   sync.Mutex
+	cmd  *exec.Cmd
+	done chan error
+}
+
+// Start starts the child with the environment variables
+func (s *Supervisor) Start(envVars []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cmd != nil {
+		return errors.New("the child process is already running")
+	}
+
+	cmd, err := s.Command(envVars)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	s.cmd = cmd
+	s.done = done
+	return nil
+}
+
+// Exited returns a channel that receives the result of the running child once it exits
+// on its own. A child stopped by Stop or Restart is never reported on it.
+func (s *Supervisor) Exited() <-chan error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done
+}
+
+// Signal sends a signal to the running child
+func (s *Supervisor) Signal(sig os.Signal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cmd == nil {
+		return errors.New("the child process is not running")
+	}
+	return s.cmd.Process.Signal(sig)
+}
+
+// Forward relays the signals psenv receives to the running child until stop is called
+func (s *Supervisor) Forward(sigs ...os.Signal) (stop func()) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, sigs...)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-received:
+				// the child may be between a restart, in which case there is nobody to tell
+				s.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(received)
+		close(done)
+	}
+}
+
+// Stop sends SIGTERM to the child and waits for it to exit, killing it once the
+// shutdown timeout has passed
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cmd == nil {
+		return nil
+	}
+
+	cmd, done := s.cmd, s.done
+	s.cmd, s.done = nil, nil
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		// the child has already exited, or SIGTERM is not supported on this platform
+		cmd.Process.Kill()
+		<-done
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		return fmt.Errorf("error killing the child process: %w", err)
+	}
+	<-done
+	return fmt.Errorf("the child process did not exit within %s and was killed", timeout)
+}
+
+// Restart stops the running child and starts a new one with the environment variables
+func (s *Supervisor) Restart(envVars []string) error {
+	if err := s.Stop(); err != nil {
+		// a child that had to be killed is still gone, so go on with the restart
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return s.Start(envVars)
+}
+
+// WriteEnvFile writes the environment variables to a dotenv file. The file is replaced
+// in one step, so a child reading it never sees a partial file.
+func WriteEnvFile(path string, envVars []string) error {
+	var b strings.Builder
+	for _, envVar := range envVars {
+		name, value, _ := strings.Cut(envVar, "=")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(quoteEnvValue(value))
+		b.WriteString("\n")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".psenv-env-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// quoteEnvValue double quotes values that a dotenv parser would otherwise misread
+func quoteEnvValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\r\"'#$\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// ParseSignal parses a signal name such as HUP or SIGUSR1
+func ParseSignal(name string) (os.Signal, error) {
+	upper := strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	if sig, ok := signals[upper]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unknown signal %q", name)
+}
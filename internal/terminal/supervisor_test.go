@@ -0,0 +1,143 @@
+//go:build unix
+
+package terminal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newShellSupervisor(script string) *Supervisor {
+	return &Supervisor{
+		Command: func(envVars []string) (*exec.Cmd, error) {
+			cmd := exec.Command("/bin/sh", "-c", script)
+			cmd.Env = append(os.Environ(), envVars...)
+			return cmd, nil
+		},
+		ShutdownTimeout: 200 * time.Millisecond,
+	}
+}
+
+func TestSupervisorReportsExit(t *testing.T) {
+	s := newShellSupervisor("exit 3")
+	if err := s.Start(nil); err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+
+	err := <-s.Exited()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit code 3, got %v", err)
+	}
+}
+
+func TestSupervisorStopsGracefully(t *testing.T) {
+	s := newShellSupervisor("trap 'exit 0' TERM; while true; do sleep 0.01; done")
+	if err := s.Start(nil); err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := s.Stop(); err != nil {
+		t.Errorf("expected nil, got error %v", err)
+	}
+}
+
+func TestSupervisorKillsAfterShutdownTimeout(t *testing.T) {
+	s := newShellSupervisor("trap '' TERM; while true; do sleep 0.01; done")
+	if err := s.Start(nil); err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := s.Stop(); err == nil {
+		t.Errorf("expected an error for a killed child, got nil")
+	}
+}
+
+func TestSupervisorRestartUsesNewEnvironment(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s := newShellSupervisor(`echo "$VALUE" > ` + out + `; trap 'exit 0' TERM; while true; do sleep 0.01; done`)
+
+	if err := s.Start([]string{"VALUE=old"}); err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := s.Restart([]string{"VALUE=new"}); err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	defer s.Stop()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+	if string(data) != "new\n" {
+		t.Errorf("expected new, got %q", data)
+	}
+}
+
+func TestSupervisorForwardsSignals(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s := newShellSupervisor(`trap 'echo hup > ` + out + `; exit 0' HUP; while true; do sleep 0.01; done`)
+	if err := s.Start(nil); err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stop := s.Forward(syscall.SIGHUP)
+	defer stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+
+	select {
+	case err := <-s.Exited():
+		if err != nil {
+			t.Errorf("expected nil, got error %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("the child did not receive the forwarded signal")
+	}
+
+	data, _ := os.ReadFile(out)
+	if string(data) != "hup\n" {
+		t.Errorf("expected hup, got %q", data)
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env")
+	err := WriteEnvFile(path, []string{"PLAIN=value", "SPACED=a b", "EMPTY="})
+	if err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+	expected := "PLAIN=value\nSPACED=\"a b\"\nEMPTY=\"\"\n"
+	if string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, data)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"HUP", "SIGHUP", "hup"} {
+		sig, err := ParseSignal(name)
+		if err != nil || sig != syscall.SIGHUP {
+			t.Errorf("expected SIGHUP for %s, got %v, %v", name, sig, err)
+		}
+	}
+	if _, err := ParseSignal("NOPE"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
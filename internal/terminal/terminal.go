@@ -1,10 +1,13 @@
 package terminal
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
+	"syscall"
 )
 
 const (
@@ -30,7 +33,50 @@ func GetIsPsenvSubShell() bool {
 	return os.Getenv(SubShellVar) == "1"
 }
 
-func NewSubShell(envVars []string, args ...string) (*exec.Cmd, error) {
+// NewCommand creates a command that runs args directly instead of through a shell, so
+// arguments keep their quoting and signals and the exit status go straight to and
+// from the command. env is the complete environment of the command, see EnvOptions.BuildEnv.
+func NewCommand(env []string, args ...string) (*exec.Cmd, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command to run")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+
+	cmd.Stdout = os.Stdout
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	cmd.Env = env
+	return cmd, nil
+}
+
+// ExitCode returns the exit status to exit psenv with after a command finished with err.
+// A command killed by a signal gets 128 plus the signal number, like in a shell.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 1
+	}
+	if code := exitErr.ExitCode(); code >= 0 {
+		return code
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return 1
+}
+
+// NewSubShell creates a shell, or a shell running args with -c. env is the complete
+// environment of the shell, see EnvOptions.BuildEnv.
+func NewSubShell(env []string, args ...string) (*exec.Cmd, error) {
 	var cmd *exec.Cmd
 
 	if GetIsPsenvSubShell() {
@@ -48,7 +94,6 @@ func NewSubShell(envVars []string, args ...string) (*exec.Cmd, error) {
 	cmd.Stdin = os.Stdin
 	cmd.Stderr = os.Stderr
 
-	cmd.Env = append(os.Environ(), envVars...)
-	cmd.Env = append(cmd.Env, SubShellVarEnabled)
+	cmd.Env = append(slices.Clip(env), SubShellVarEnabled)
 	return cmd, nil
 }
@@ -2,6 +2,9 @@ package terminal
 
 import (
 	"os"
+	"os/exec"
+	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -73,3 +76,37 @@ func TestNewSubShellWithoutArgs(t *testing.T) {
 		t.Errorf("expected %v, got %v", expected, cmd.Path)
 	}
 }
+
+func TestNewCommandKeepsArguments(t *testing.T) {
+	cmd, err := NewCommand([]string{"VAR=value"}, "echo", "hello world", "$HOME")
+	if err != nil {
+		t.Fatalf("expected nil, got error %v", err)
+	}
+	expected := []string{"echo", "hello world", "$HOME"}
+	if strings.Join(cmd.Args, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v, got %v", expected, cmd.Args)
+	}
+}
+
+func TestNewCommandNotFound(t *testing.T) {
+	_, err := NewCommand(nil, "psenv-command-that-does-not-exist")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	err := exec.Command("/bin/sh", "-c", "exit 3").Run()
+	if code := ExitCode(err); code != 3 {
+		t.Errorf("expected 3, got %v", code)
+	}
+
+	err = exec.Command("/bin/sh", "-c", "kill -TERM $$").Run()
+	if code := ExitCode(err); code != 128+int(syscall.SIGTERM) {
+		t.Errorf("expected %v, got %v", 128+int(syscall.SIGTERM), code)
+	}
+
+	if code := ExitCode(nil); code != 0 {
+		t.Errorf("expected 0, got %v", code)
+	}
+}
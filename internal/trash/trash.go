@@ -0,0 +1,180 @@
+// Package trash keeps copies of deleted parameters so they can be restored. Every
+// delete is a batch named after the time it happened, and its parameters are kept at
+// <trash path>/<batch>/<env>/<key>, e.g. /prefix/project/.trash/20240501T120000.000Z/dev/KEY.
+package trash
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+)
+
+// BatchFormat names a batch after the time of the delete, in UTC
+const BatchFormat = "20060102T150405.000Z"
+
+// Batch returns the name of the batch for a delete at a time
+func Batch(at time.Time) string {
+	return at.UTC().Format(BatchFormat)
+}
+
+// Entry is a deleted parameter in the trash
+type Entry struct {
+	Batch string
+	Env   string
+	Key   string
+
+	// Param is the copy in the trash
+	Param parameterstore.Parameter
+}
+
+// DeletedAt returns when the parameter was deleted
+func (e Entry) DeletedAt() (time.Time, error) {
+	return time.Parse(BatchFormat, e.Batch)
+}
+
+// Put copies parameters that are about to be deleted from an environment into a batch
+// of the trash and returns the copies, e.g. to purge them when the delete is undone.
+// Expiration policies are dropped so the copies don't expire with them.
+func Put(ctx context.Context, ps *parameterstore.ParameterStore, root, batch, env, envPath string, params map[string]parameterstore.Parameter, keyID string) ([]Entry, error) {
+	copies := make(map[string]parameterstore.Parameter, len(params))
+	entries := make([]Entry, 0, len(params))
+	for name, param := range params {
+		key, ok := config.RelativeKey(envPath, name)
+		if !ok {
+			return nil, fmt.Errorf("parameter %s is not below %s", name, envPath)
+		}
+
+		param.Name = strings.Join([]string{root, batch, env, key}, config.KeySeparator)
+		param.Version = 0
+		param.Policies = parameterstore.Policies{}
+		copies[param.Name] = param
+		entries = append(entries, Entry{Batch: batch, Env: env, Key: key, Param: param})
+	}
+
+	if _, err := ps.PutParameters(ctx, copies, keyID, false); err != nil {
+		return nil, fmt.Errorf("error moving parameters to the trash: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// List returns every entry of the trash of an environment, oldest batch first
+func List(ctx context.Context, ps *parameterstore.ParameterStore, root, env string) ([]Entry, error) {
+	params, err := ps.GetParametersWithMetadata(ctx, root, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for name, param := range params {
+		relative, ok := config.RelativeKey(root, name)
+		if !ok {
+			continue
+		}
+
+		levels := strings.SplitN(relative, config.KeySeparator, 3)
+		if len(levels) < 3 || levels[1] != env {
+			continue
+		}
+		entries = append(entries, Entry{Batch: levels[0], Env: levels[1], Key: levels[2], Param: param})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Batch != entries[j].Batch {
+			return entries[i].Batch < entries[j].Batch
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+// Restore puts entries back in their environment and then removes them from the
+// trash. Unless overwrite is set, a parameter that exists again is an error and
+// nothing is restored.
+func Restore(ctx context.Context, ps *parameterstore.ParameterStore, entries []Entry, envPath, keyID string, overwrite bool) ([]string, error) {
+	if !strings.HasPrefix(envPath, config.KeySeparator) || envPath == config.KeySeparator {
+		return nil, fmt.Errorf("can't restore to %q, it is not the path of an environment", envPath)
+	}
+
+	restored := make(map[string]parameterstore.Parameter, len(entries))
+	for _, entry := range entries {
+		name := envPath + config.KeySeparator + entry.Key
+		if _, ok := restored[name]; ok {
+			return nil, fmt.Errorf("%s is in more than one batch, restore one batch at a time", entry.Key)
+		}
+
+		param := entry.Param
+		param.Name = name
+		param.Version = 0
+		restored[name] = param
+	}
+
+	if !overwrite {
+		existing, err := ps.GetParameters(ctx, envPath, false)
+		if err != nil {
+			return nil, err
+		}
+
+		var conflicts []string
+		for name := range restored {
+			if _, ok := existing[name]; ok {
+				conflicts = append(conflicts, name)
+			}
+		}
+		if len(conflicts) > 0 {
+			sort.Strings(conflicts)
+			return nil, fmt.Errorf("parameters exist again, use --overwrite to replace them: %s", strings.Join(conflicts, ", "))
+		}
+	}
+
+	if _, err := ps.PutParameters(ctx, restored, keyID, overwrite); err != nil {
+		return nil, fmt.Errorf("error restoring parameters: %w", err)
+	}
+
+	names := make([]string, 0, len(restored))
+	for name := range restored {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, Purge(ctx, ps, entries)
+}
+
+// Purge deletes entries from the trash for good
+func Purge(ctx context.Context, ps *parameterstore.ParameterStore, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Param.Name)
+	}
+	if err := ps.DeleteParameters(ctx, names); err != nil {
+		return fmt.Errorf("error removing parameters from the trash: %w", err)
+	}
+	return nil
+}
+
+// Select returns the entries of a batch, or of every batch when batch is empty, that
+// were deleted before a time. A zero time selects entries deleted at any time.
+func Select(entries []Entry, batch string, before time.Time) []Entry {
+	var selected []Entry
+	for _, entry := range entries {
+		if batch != "" && entry.Batch != batch {
+			continue
+		}
+		if !before.IsZero() {
+			deletedAt, err := entry.DeletedAt()
+			if err != nil || !deletedAt.Before(before) {
+				continue
+			}
+		}
+		selected = append(selected, entry)
+	}
+	return selected
+}
@@ -0,0 +1,139 @@
+package trash
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/devserver/devservertest"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 250_000_000, time.FixedZone("CEST", 2*60*60))
+	require.Equal(t, "20240501T100000.250Z", Batch(at))
+
+	deletedAt, err := Entry{Batch: Batch(at)}.DeletedAt()
+	require.NoError(t, err)
+	require.True(t, deletedAt.Equal(at))
+}
+
+func TestSelect(t *testing.T) {
+	entries := []Entry{
+		{Batch: "20240101T000000.000Z", Key: "OLD"},
+		{Batch: "20240601T000000.000Z", Key: "NEW"},
+	}
+	require.Len(t, Select(entries, "", time.Time{}), 2)
+	require.Equal(t, "NEW", Select(entries, "20240601T000000.000Z", time.Time{})[0].Key)
+
+	before := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, []Entry{entries[0]}, Select(entries, "", before))
+	require.Empty(t, Select(entries, "20240601T000000.000Z", before))
+}
+
+func TestPutListRestorePurge(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+	ctx := context.Background()
+	root := "/psenv/foobar/.trash"
+
+	_, err := ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEY1":        {Value: "value1", Description: "first"},
+		"/psenv/foobar/dev/db/PASSWORD": {Value: "secret", Type: "SecureString"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+	deleted, err := ps.GetParametersWithMetadata(ctx, "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+
+	batch := Batch(time.Now())
+	copies, err := Put(ctx, ps, root, batch, "dev", "/psenv/foobar/dev", deleted, "alias/aws/ssm")
+	require.NoError(t, err)
+	require.Len(t, copies, 2)
+	require.Equal(t, root+"/"+batch+"/dev/KEY1", copies[0].Param.Name)
+	require.NoError(t, ps.DeleteParameters(ctx, []string{"/psenv/foobar/dev/KEY1", "/psenv/foobar/dev/db/PASSWORD"}))
+
+	entries, err := List(ctx, ps, root, "dev")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "KEY1", entries[0].Key)
+	require.Equal(t, "db/PASSWORD", entries[1].Key)
+	require.Equal(t, root+"/"+batch+"/dev/db/PASSWORD", entries[1].Param.Name)
+	require.Equal(t, "secret", entries[1].Param.Value)
+
+	others, err := List(ctx, ps, root, "prod")
+	require.NoError(t, err)
+	require.Empty(t, others)
+
+	// KEY1 exists again, so nothing is restored without overwrite
+	_, err = ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/KEY1": {Value: "again"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+	_, err = Restore(ctx, ps, entries, "/psenv/foobar/dev", "alias/aws/ssm", false)
+	require.ErrorContains(t, err, "/psenv/foobar/dev/KEY1")
+
+	restored, err := Restore(ctx, ps, entries, "/psenv/foobar/dev", "alias/aws/ssm", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/psenv/foobar/dev/KEY1", "/psenv/foobar/dev/db/PASSWORD"}, restored)
+
+	params, err := ps.GetParametersWithMetadata(ctx, "/psenv/foobar/dev", true)
+	require.NoError(t, err)
+	require.Equal(t, "value1", params["/psenv/foobar/dev/KEY1"].Value)
+	require.Equal(t, "first", params["/psenv/foobar/dev/KEY1"].Description)
+	require.Equal(t, "secret", params["/psenv/foobar/dev/db/PASSWORD"].Value)
+
+	// restoring empties the batch
+	entries, err = List(ctx, ps, root, "dev")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	copies, err = Put(ctx, ps, root, batch, "dev", "/psenv/foobar/dev", params, "alias/aws/ssm")
+	require.NoError(t, err)
+	require.NoError(t, Purge(ctx, ps, copies))
+	entries, err = List(ctx, ps, root, "dev")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestRestoreAfterDelete(t *testing.T) {
+	ps := devservertest.NewParameterStore(t)
+	ctx := context.Background()
+
+	projectConfig := &config.ProjectConfig{Prefix: "/psenv", Project: "foobar"}
+	projectConfig.AddEnvironment("dev")
+	envPath := projectConfig.GetEnvironmentPath("dev")
+
+	_, err := ps.PutParameters(ctx, map[string]parameterstore.Parameter{
+		envPath + "/DB_PASSWORD": {Value: "secret"},
+		envPath + "/db/HOST":     {Value: "localhost"},
+	}, "alias/aws/ssm", false)
+	require.NoError(t, err)
+	deleted, err := ps.GetParametersWithMetadata(ctx, envPath, true)
+	require.NoError(t, err)
+
+	// psenv delete trashes the parameters and removes the environment from the project
+	_, err = Put(ctx, ps, projectConfig.GetTrashPath(), Batch(time.Now()), "dev", envPath, deleted, "alias/aws/ssm")
+	require.NoError(t, err)
+	require.NoError(t, ps.DeleteParameters(ctx, []string{envPath + "/DB_PASSWORD", envPath + "/db/HOST"}))
+	projectConfig.RemoveEnvironment("dev")
+	require.Empty(t, projectConfig.GetEnvironmentPath("dev"))
+
+	entries, err := List(ctx, ps, projectConfig.GetTrashPath(), "dev")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// an empty path never writes to the root or empties the trash
+	_, err = Restore(ctx, ps, entries, projectConfig.GetEnvironmentPath("dev"), "alias/aws/ssm", true)
+	require.ErrorContains(t, err, "not the path of an environment")
+	root, err := ps.GetParameters(ctx, "/", true)
+	require.NoError(t, err)
+	require.NotContains(t, root, "/DB_PASSWORD")
+	entries, err = List(ctx, ps, projectConfig.GetTrashPath(), "dev")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	restored, err := Restore(ctx, ps, entries, projectConfig.ExpandEnvironmentPath("dev"), "alias/aws/ssm", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/psenv/foobar/dev/DB_PASSWORD", "/psenv/foobar/dev/db/HOST"}, restored)
+}
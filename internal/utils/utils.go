@@ -1,17 +1,22 @@
 package utils
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+)
 
 type Parameters struct {
-	ToAdd    map[string]string
-	ToUpdate map[string]string
+	ToAdd    map[string]parameterstore.Parameter
+	ToUpdate map[string]parameterstore.Parameter
 	ToDelete []string
 }
 
-func MergeLocalAndRemoteParams(localParams, remoteParams map[string]string) *Parameters {
+func MergeLocalAndRemoteParams(localParams, remoteParams map[string]parameterstore.Parameter) *Parameters {
 	var toMerge = &Parameters{
-		ToAdd:    make(map[string]string),
-		ToUpdate: make(map[string]string),
+		ToAdd:    make(map[string]parameterstore.Parameter),
+		ToUpdate: make(map[string]parameterstore.Parameter),
 		ToDelete: make([]string, 0),
 	}
 
@@ -24,8 +29,13 @@ func MergeLocalAndRemoteParams(localParams, remoteParams map[string]string) *Par
 			continue
 		}
 
-		// if the local param exists in the remote params, but the value is different, then it must be updated
-		if localValue != remoteValue {
+		// the parameter store can't move an advanced parameter back to the standard tier
+		if remoteValue.Tier == types.ParameterTierAdvanced && localValue.EffectiveTier() == types.ParameterTierStandard {
+			localValue.Tier = types.ParameterTierAdvanced
+		}
+
+		// if the local param exists in the remote params, but the value or type is different, then it must be updated
+		if localValue.Differs(remoteValue) {
 			toMerge.ToUpdate[localKey] = localValue
 			continue
 		}
@@ -2,16 +2,20 @@ package utils
 
 import (
 	"reflect"
+	"sort"
 	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
 )
 
 func TestMergeLocalAndRemoteParams_AddsNewParams(t *testing.T) {
-	localParams := map[string]string{"key1": "value1"}
-	remoteParams := map[string]string{}
+	localParams := map[string]parameterstore.Parameter{"key1": {Value: "value1"}}
+	remoteParams := map[string]parameterstore.Parameter{}
 
 	expected := &Parameters{
-		ToAdd:    map[string]string{"key1": "value1"},
-		ToUpdate: map[string]string{},
+		ToAdd:    map[string]parameterstore.Parameter{"key1": {Value: "value1"}},
+		ToUpdate: map[string]parameterstore.Parameter{},
 		ToDelete: []string{},
 	}
 
@@ -22,12 +26,12 @@ func TestMergeLocalAndRemoteParams_AddsNewParams(t *testing.T) {
 }
 
 func TestMergeLocalAndRemoteParams_UpdatesExistingParams(t *testing.T) {
-	localParams := map[string]string{"key1": "newValue"}
-	remoteParams := map[string]string{"key1": "oldValue"}
+	localParams := map[string]parameterstore.Parameter{"key1": {Value: "newValue"}}
+	remoteParams := map[string]parameterstore.Parameter{"key1": {Value: "oldValue"}}
 
 	expected := &Parameters{
-		ToAdd:    map[string]string{},
-		ToUpdate: map[string]string{"key1": "newValue"},
+		ToAdd:    map[string]parameterstore.Parameter{},
+		ToUpdate: map[string]parameterstore.Parameter{"key1": {Value: "newValue"}},
 		ToDelete: []string{},
 	}
 
@@ -38,12 +42,12 @@ func TestMergeLocalAndRemoteParams_UpdatesExistingParams(t *testing.T) {
 }
 
 func TestMergeLocalAndRemoteParams_DeletesMissingParams(t *testing.T) {
-	localParams := map[string]string{}
-	remoteParams := map[string]string{"key1": "value1"}
+	localParams := map[string]parameterstore.Parameter{}
+	remoteParams := map[string]parameterstore.Parameter{"key1": {Value: "value1"}}
 
 	expected := &Parameters{
-		ToAdd:    map[string]string{},
-		ToUpdate: map[string]string{},
+		ToAdd:    map[string]parameterstore.Parameter{},
+		ToUpdate: map[string]parameterstore.Parameter{},
 		ToDelete: []string{"key1"},
 	}
 
@@ -54,12 +58,28 @@ func TestMergeLocalAndRemoteParams_DeletesMissingParams(t *testing.T) {
 }
 
 func TestMergeLocalAndRemoteParams_NoChanges(t *testing.T) {
-	localParams := map[string]string{"key1": "value1"}
-	remoteParams := map[string]string{"key1": "value1"}
+	localParams := map[string]parameterstore.Parameter{"key1": {Value: "value1"}}
+	remoteParams := map[string]parameterstore.Parameter{"key1": {Value: "value1", Type: types.ParameterTypeSecureString, Version: 2}}
 
 	expected := &Parameters{
-		ToAdd:    map[string]string{},
-		ToUpdate: map[string]string{},
+		ToAdd:    map[string]parameterstore.Parameter{},
+		ToUpdate: map[string]parameterstore.Parameter{},
+		ToDelete: []string{},
+	}
+
+	result := MergeLocalAndRemoteParams(localParams, remoteParams)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestMergeLocalAndRemoteParams_UpdatesChangedType(t *testing.T) {
+	localParams := map[string]parameterstore.Parameter{"key1": {Value: "a,b", Type: types.ParameterTypeStringList}}
+	remoteParams := map[string]parameterstore.Parameter{"key1": {Value: "a,b", Type: types.ParameterTypeSecureString}}
+
+	expected := &Parameters{
+		ToAdd:    map[string]parameterstore.Parameter{},
+		ToUpdate: map[string]parameterstore.Parameter{"key1": {Value: "a,b", Type: types.ParameterTypeStringList}},
 		ToDelete: []string{},
 	}
 
@@ -74,6 +94,7 @@ func TestConvertParamsToEnvVars(t *testing.T) {
 	expected := []string{"key1=value1", "key2=value2"}
 
 	result := ConvertParamsToEnvVars(params)
+	sort.Strings(result)
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("expected %v, got %v", expected, result)
 	}
@@ -84,7 +105,24 @@ func TestConvertParamsToEnvVars_WithSlashes(t *testing.T) {
 	expected := []string{"key1=value1", "key2=value2"}
 
 	result := ConvertParamsToEnvVars(params)
+	sort.Strings(result)
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("expected %v, got %v", expected, result)
 	}
 }
+
+func TestMergeLocalAndRemoteParams_KeepsAdvancedTier(t *testing.T) {
+	localParams := map[string]parameterstore.Parameter{"key1": {Value: "value1"}}
+	remoteParams := map[string]parameterstore.Parameter{"key1": {Value: "value1", Tier: types.ParameterTierAdvanced}}
+
+	result := MergeLocalAndRemoteParams(localParams, remoteParams)
+	if len(result.ToUpdate) != 0 {
+		t.Errorf("expected no updates, got %v", result.ToUpdate)
+	}
+
+	localParams["key1"] = parameterstore.Parameter{Value: "changed"}
+	result = MergeLocalAndRemoteParams(localParams, remoteParams)
+	if result.ToUpdate["key1"].Tier != types.ParameterTierAdvanced {
+		t.Errorf("expected the update to stay advanced, got %v", result.ToUpdate["key1"])
+	}
+}
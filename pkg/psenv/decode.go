@@ -0,0 +1,223 @@
+package psenv
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is the error for a single struct field that could not be decoded
+type FieldError struct {
+	// Field is the name of the struct field, including the names of any parent structs
+	Field string
+
+	// Name is the environment variable name the field is decoded from
+	Name string
+
+	// Path is the parameter store name the value was read from. It is empty when the
+	// value is missing or came from a default.
+	Path string
+
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	source := e.Name
+	if e.Path != "" {
+		source = fmt.Sprintf("%s (%s)", e.Name, e.Path)
+	}
+	return fmt.Sprintf("%s: field %s: %s", source, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ErrRequired is the error of a FieldError for a required value that is not set
+var ErrRequired = errors.New("required value is not set")
+
+// Unmarshal decodes the values of the environment into the struct pointed to by v.
+// Errors name the parameter store name of every bad value. See Decode for how fields
+// are matched to values.
+func (e *Environment) Unmarshal(v interface{}) error {
+	return decode(e.Values, e.Path, v)
+}
+
+// Decode decodes values into the struct pointed to by v. Fields are matched by their
+// psenv tag, which can mark a value as required, and a default tag gives the value
+// used when one is missing:
+//
+//	type Config struct {
+//		DatabaseURL *url.URL      `psenv:"DATABASE_URL,required"`
+//		Port        int           `psenv:"DB_PORT" default:"5432"`
+//		Timeout     time.Duration `psenv:"TIMEOUT" default:"30s"`
+//		Hosts       []string      `psenv:"HOSTS"`
+//	}
+//
+// Strings, bools, ints, uints, floats, durations, urls, types implementing
+// encoding.TextUnmarshaler, pointers to these and comma separated slices of them are
+// supported. Untagged struct fields are decoded recursively. The env tag is accepted
+// in place of the psenv tag. Every bad value is reported, joined into one error of
+// *FieldError values.
+func Decode(values map[string]string, v interface{}) error {
+	return decode(values, func(string) string { return "" }, v)
+}
+
+func decode(values map[string]string, path func(name string) string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("psenv: decoding needs a non nil pointer to a struct, got %T", v)
+	}
+
+	d := &decoder{values: values, path: path}
+	d.decodeStruct(rv.Elem(), "")
+	return errors.Join(d.errs...)
+}
+
+type decoder struct {
+	values map[string]string
+	path   func(name string) string
+	errs   []error
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (d *decoder) decodeStruct(rv reflect.Value, parent string) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldName := parent + field.Name
+		tag, ok := field.Tag.Lookup("psenv")
+		if !ok {
+			tag, ok = field.Tag.Lookup("env")
+		}
+
+		if !ok {
+			if field.Type.Kind() == reflect.Struct && !isScalar(field.Type) {
+				d.decodeStruct(rv.Field(i), fieldName+".")
+			}
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		required := options == "required"
+
+		value, found := d.values[name]
+		valuePath := d.path(name)
+		if !found {
+			value, found = field.Tag.Lookup("default")
+			valuePath = ""
+		}
+
+		if !found {
+			if required {
+				d.errs = append(d.errs, &FieldError{Field: fieldName, Name: name, Err: ErrRequired})
+			}
+			continue
+		}
+
+		if err := setValue(rv.Field(i), value); err != nil {
+			d.errs = append(d.errs, &FieldError{Field: fieldName, Name: name, Path: valuePath, Err: err})
+		}
+	}
+}
+
+// isScalar reports whether a type is decoded from a single value rather than field by field
+func isScalar(t reflect.Type) bool {
+	return t == urlType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		target := reflect.New(field.Type().Elem())
+		if err := setValue(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	if field.CanAddr() {
+		if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(value))
+		}
+	}
+
+	switch field.Type() {
+	case durationType:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	case urlType:
+		u, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(*u))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		// StringList parameters are comma joined, so slices are too
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ",")
+		}
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		field.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
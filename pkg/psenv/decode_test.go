@@ -0,0 +1,98 @@
+package psenv
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeTypes(t *testing.T) {
+	var cfg struct {
+		Name     string        `psenv:"NAME"`
+		Port     int           `psenv:"DB_PORT" default:"5432"`
+		Workers  uint8         `psenv:"WORKERS"`
+		Ratio    float64       `psenv:"RATIO"`
+		Debug    bool          `psenv:"DEBUG"`
+		Timeout  time.Duration `psenv:"TIMEOUT" default:"30s"`
+		Hosts    []string      `psenv:"HOSTS"`
+		Ports    []int         `psenv:"PORTS"`
+		Endpoint url.URL       `psenv:"ENDPOINT"`
+		Database *url.URL      `psenv:"DATABASE_URL,required"`
+		Address  netip.Addr    `psenv:"ADDRESS"`
+		Retries  *int          `psenv:"RETRIES"`
+		Legacy   string        `env:"LEGACY"`
+		Skipped  string        `psenv:"-"`
+		Nested   struct {
+			Level string `psenv:"LOG_LEVEL" default:"info"`
+		}
+	}
+
+	err := Decode(map[string]string{
+		"NAME":         "api",
+		"WORKERS":      "8",
+		"RATIO":        "0.5",
+		"DEBUG":        "true",
+		"TIMEOUT":      "2m",
+		"HOSTS":        "a.example.com, b.example.com",
+		"PORTS":        "80,443",
+		"ENDPOINT":     "https://api.example.com/v1",
+		"DATABASE_URL": "postgres://localhost:5432/db",
+		"ADDRESS":      "10.0.0.1",
+		"RETRIES":      "3",
+		"LEGACY":       "old",
+	}, &cfg)
+	require.NoError(t, err)
+
+	require.Equal(t, "api", cfg.Name)
+	require.Equal(t, 5432, cfg.Port)
+	require.Equal(t, uint8(8), cfg.Workers)
+	require.Equal(t, 0.5, cfg.Ratio)
+	require.True(t, cfg.Debug)
+	require.Equal(t, 2*time.Minute, cfg.Timeout)
+	require.Equal(t, []string{"a.example.com", "b.example.com"}, cfg.Hosts)
+	require.Equal(t, []int{80, 443}, cfg.Ports)
+	require.Equal(t, "api.example.com", cfg.Endpoint.Host)
+	require.Equal(t, "/db", cfg.Database.Path)
+	require.Equal(t, netip.MustParseAddr("10.0.0.1"), cfg.Address)
+	require.Equal(t, 3, *cfg.Retries)
+	require.Equal(t, "old", cfg.Legacy)
+	require.Equal(t, "info", cfg.Nested.Level)
+}
+
+func TestDecodeAggregatesErrors(t *testing.T) {
+	env := &Environment{
+		Values: map[string]string{
+			"DB_PORT": "not a number",
+			"TIMEOUT": "soon",
+		},
+		paths: map[string]string{
+			"DB_PORT": "/psenv/foobar/dev/DB_PORT",
+			"TIMEOUT": "/psenv/foobar/base/TIMEOUT",
+		},
+	}
+
+	var cfg struct {
+		Port     int           `psenv:"DB_PORT"`
+		Timeout  time.Duration `psenv:"TIMEOUT"`
+		Database string        `psenv:"DATABASE_URL,required"`
+	}
+
+	err := env.Unmarshal(&cfg)
+	require.ErrorContains(t, err, "DB_PORT (/psenv/foobar/dev/DB_PORT)")
+	require.ErrorContains(t, err, "TIMEOUT (/psenv/foobar/base/TIMEOUT)")
+	require.ErrorIs(t, err, ErrRequired)
+
+	var fieldErr *FieldError
+	require.True(t, errors.As(err, &fieldErr))
+	require.Equal(t, "Port", fieldErr.Field)
+}
+
+func TestDecodeNeedsStructPointer(t *testing.T) {
+	var cfg struct{}
+	require.Error(t, Decode(nil, cfg))
+	require.NoError(t, Decode(nil, &cfg))
+}
@@ -0,0 +1,187 @@
+// Package psenv loads psenv environments from the parameter store into a running Go
+// program, as an alternative to wrapping it with psenv exec.
+//
+//	values, err := psenv.Load(ctx, ".", "prod")
+//
+// The project is resolved exactly like the psenv cli does it: the accounts, roles and
+// paths of each environment come from psenv-project.yml, and the parameters of the
+// environment are layered on top of the ones in the base environment, if the project has one.
+package psenv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pytoolbelt/psenv/internal/config"
+	"github.com/pytoolbelt/psenv/internal/engine"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+)
+
+// BaseEnvironment is the environment every other environment inherits parameters from
+const BaseEnvironment = config.BaseEnvironment
+
+// Option configures how an environment is loaded
+type Option func(*options)
+
+type options struct {
+	clientOptions []parameterstore.Option
+}
+
+// WithProfile uses a named profile from the shared AWS config files for every environment
+func WithProfile(profile string) Option {
+	return func(o *options) {
+		o.clientOptions = append(o.clientOptions, parameterstore.WithProfile(profile))
+	}
+}
+
+// WithRegion overrides the region of every environment
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.clientOptions = append(o.clientOptions, parameterstore.WithRegion(region))
+	}
+}
+
+// WithEndpointURL sends every request to a custom endpoint, such as LocalStack or the psenv dev server
+func WithEndpointURL(url string) Option {
+	return func(o *options) {
+		o.clientOptions = append(o.clientOptions, parameterstore.WithEndpointURL(url))
+	}
+}
+
+// WithStaticCredentials uses fixed credentials instead of the default credential chain
+func WithStaticCredentials(accessKeyID, secretAccessKey string) Option {
+	return func(o *options) {
+		o.clientOptions = append(o.clientOptions, parameterstore.WithStaticCredentials(accessKeyID, secretAccessKey))
+	}
+}
+
+// Environment holds the decrypted parameters of a loaded environment
+type Environment struct {
+	// Name is the name of the environment that was loaded
+	Name string
+
+	// Values maps environment variable names to parameter values
+	Values map[string]string
+
+	// paths maps environment variable names to the parameter they were read from
+	paths map[string]string
+}
+
+// Path returns the parameter store name a value was read from, or "" if there is no such value
+func (e *Environment) Path(name string) string {
+	return e.paths[name]
+}
+
+// Names returns the names of all values, sorted
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.Values))
+	for name := range e.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Setenv sets every value as an environment variable of the current process
+func (e *Environment) Setenv() error {
+	for _, name := range e.Names() {
+		if err := os.Setenv(name, e.Values[name]); err != nil {
+			return fmt.Errorf("error setting %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// LoadEnvironment loads an environment of a project. project is the directory holding
+// psenv-project.yml, or the path of the project file itself.
+func LoadEnvironment(ctx context.Context, project, env string, opts ...Option) (*Environment, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	projectConfig, err := loadProjectConfig(project)
+	if err != nil {
+		return nil, err
+	}
+
+	if !projectConfig.HasEnvironment(env) {
+		return nil, fmt.Errorf("environment %s does not exist in the project configuration", env)
+	}
+
+	envs := projectConfig.LayeredEnvironments(env)
+
+	e := &engine.Engine{
+		NewClient: func(env string) (*parameterstore.ParameterStore, error) {
+			clientOptions := projectConfig.GetClientOptions(env)
+			endpoint := config.EndpointFromEnv()
+			clientOptions = append(clientOptions, endpoint.ClientOptions()...)
+			return parameterstore.New(append(clientOptions, o.clientOptions...)...)
+		},
+		Path: projectConfig.GetEnvironmentPath,
+	}
+
+	results := e.Get(ctx, envs, engine.GetOptions{Decrypt: true})
+	if err := results.Err(); err != nil {
+		return nil, err
+	}
+
+	layers := make([]config.Layer, 0, len(results))
+	for _, result := range results {
+		layers = append(layers, config.Layer{Path: result.Path, Params: result.Params})
+	}
+
+	values, paths, err := projectConfig.Naming.EnvVars(layers...)
+	if err != nil {
+		return nil, err
+	}
+	return &Environment{Name: env, Values: values, paths: paths}, nil
+}
+
+// Load loads an environment of a project and returns its values by environment variable name
+func Load(ctx context.Context, project, env string, opts ...Option) (map[string]string, error) {
+	loaded, err := LoadEnvironment(ctx, project, env, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return loaded.Values, nil
+}
+
+// Setenv loads an environment of a project and sets its values as environment variables
+func Setenv(ctx context.Context, project, env string, opts ...Option) error {
+	loaded, err := LoadEnvironment(ctx, project, env, opts...)
+	if err != nil {
+		return err
+	}
+	return loaded.Setenv()
+}
+
+// Unmarshal loads an environment of a project into the struct pointed to by v.
+// See Environment.Unmarshal for how fields are matched to values.
+func Unmarshal(ctx context.Context, project, env string, v interface{}, opts ...Option) error {
+	loaded, err := LoadEnvironment(ctx, project, env, opts...)
+	if err != nil {
+		return err
+	}
+	return loaded.Unmarshal(v)
+}
+
+func loadProjectConfig(project string) (*config.ProjectConfig, error) {
+	if project == "" {
+		project = "."
+	}
+
+	file := project
+	if info, err := os.Stat(project); err == nil && info.IsDir() {
+		file = filepath.Join(project, config.ProjectConfigFile)
+	}
+
+	projectConfig, err := config.LoadProjectConfigFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error loading project config %s: %w", file, err)
+	}
+	return projectConfig, nil
+}
@@ -0,0 +1,133 @@
+package psenv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pytoolbelt/psenv/internal/devserver/devservertest"
+	"github.com/pytoolbelt/psenv/internal/parameterstore"
+	"github.com/stretchr/testify/require"
+)
+
+const testProject = `default: dev
+environments: [base, dev, prod]
+prefix: /psenv
+project: foobar
+`
+
+// newTestProject writes a project file to a temporary directory and returns the
+// directory along with the options to reach a dev server holding the parameters
+func newTestProject(t *testing.T, params map[string]parameterstore.Parameter) (string, []Option) {
+	url := devservertest.Start(t, "")
+	opts := []Option{
+		WithEndpointURL(url),
+		WithRegion(devservertest.Region),
+		WithStaticCredentials(devservertest.AccessKeyID, devservertest.SecretAccessKey),
+	}
+
+	_, err := devservertest.Connect(t, url).PutParameters(context.Background(), params, "alias/aws/ssm", false)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "psenv-project.yml"), []byte(testProject), 0644))
+	return dir, opts
+}
+
+func TestLoadInheritsFromBase(t *testing.T) {
+	dir, opts := newTestProject(t, map[string]parameterstore.Parameter{
+		"/psenv/foobar/base/LOG_LEVEL": {Value: "info"},
+		"/psenv/foobar/base/REGION":    {Value: "us-east-1"},
+		"/psenv/foobar/dev/LOG_LEVEL":  {Value: "debug"},
+		"/psenv/foobar/prod/LOG_LEVEL": {Value: "warn"},
+	})
+
+	values, err := Load(context.Background(), dir, "dev", opts...)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"LOG_LEVEL": "debug", "REGION": "us-east-1"}, values)
+}
+
+func TestLoadWithoutBase(t *testing.T) {
+	dir, opts := newTestProject(t, map[string]parameterstore.Parameter{
+		"/psenv/foobar/base/REGION":   {Value: "us-east-1"},
+		"/psenv/foobar/dev/LOG_LEVEL": {Value: "debug"},
+	})
+	project := "default: dev\nenvironments: [dev]\nprefix: /psenv\nproject: foobar\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "psenv-project.yml"), []byte(project), 0644))
+
+	values, err := Load(context.Background(), dir, "dev", opts...)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"LOG_LEVEL": "debug"}, values)
+}
+
+func TestLoadNestedParameters(t *testing.T) {
+	dir, opts := newTestProject(t, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/LOG_LEVEL":           {Value: "debug"},
+		"/psenv/foobar/dev/db/PASSWORD":         {Value: "secret"},
+		"/psenv/foobar/dev/db/replica/PASSWORD": {Value: "replica"},
+	})
+
+	env, err := LoadEnvironment(context.Background(), dir, "dev", opts...)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"LOG_LEVEL":           "debug",
+		"DB_PASSWORD":         "secret",
+		"DB_REPLICA_PASSWORD": "replica",
+	}, env.Values)
+	require.Equal(t, "/psenv/foobar/dev/db/replica/PASSWORD", env.Path("DB_REPLICA_PASSWORD"))
+}
+
+func TestLoadRejectsUnknownEnvironment(t *testing.T) {
+	dir, opts := newTestProject(t, nil)
+
+	_, err := Load(context.Background(), filepath.Join(dir, "psenv-project.yml"), "staging", opts...)
+	require.ErrorContains(t, err, "staging")
+}
+
+func TestSetenv(t *testing.T) {
+	dir, opts := newTestProject(t, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/PSENV_TEST_SETENV": {Value: "set"},
+	})
+	t.Setenv("PSENV_TEST_SETENV", "")
+
+	require.NoError(t, Setenv(context.Background(), dir, "dev", opts...))
+	require.Equal(t, "set", os.Getenv("PSENV_TEST_SETENV"))
+}
+
+func TestUnmarshal(t *testing.T) {
+	dir, opts := newTestProject(t, map[string]parameterstore.Parameter{
+		"/psenv/foobar/dev/DATABASE_URL": {Value: "postgres://localhost/db"},
+		"/psenv/foobar/dev/PORT":         {Value: "5432"},
+		"/psenv/foobar/dev/DEBUG":        {Value: "true"},
+	})
+
+	var cfg struct {
+		DatabaseURL string `env:"DATABASE_URL"`
+		Port        int    `env:"PORT"`
+		Debug       bool   `env:"DEBUG"`
+		Missing     string `env:"MISSING"`
+		Untagged    string
+	}
+	cfg.Missing = "unchanged"
+
+	require.NoError(t, Unmarshal(context.Background(), dir, "dev", &cfg, opts...))
+	require.Equal(t, "postgres://localhost/db", cfg.DatabaseURL)
+	require.Equal(t, 5432, cfg.Port)
+	require.True(t, cfg.Debug)
+	require.Equal(t, "unchanged", cfg.Missing)
+}
+
+func TestUnmarshalNamesTheParameterOfBadValues(t *testing.T) {
+	env := &Environment{
+		Values: map[string]string{"PORT": "not a number"},
+		paths:  map[string]string{"PORT": "/psenv/foobar/dev/PORT"},
+	}
+
+	var cfg struct {
+		Port int `env:"PORT"`
+	}
+	err := env.Unmarshal(&cfg)
+	require.ErrorContains(t, err, "/psenv/foobar/dev/PORT")
+	require.Error(t, env.Unmarshal(cfg))
+}